@@ -9,10 +9,12 @@ import (
 	"runtime/debug"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/diamondburned/gotk4/pkg/gio/v2"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app/locale"
 	"github.com/diamondburned/gotkit/components/errpopup"
 	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
@@ -20,9 +22,17 @@ import (
 	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
 )
 
-var isDevel bool
+var (
+	isDevel  atomic.Bool
+	develSet atomic.Bool // true if isDevel was explicitly set
+)
 
 func init() {
+	if devel := os.Getenv("GOTKIT_DEVEL"); devel != "" {
+		SetDevel(devel != "0" && devel != "false")
+		return
+	}
+
 	build, ok := debug.ReadBuildInfo()
 	if !ok {
 		return
@@ -39,13 +49,33 @@ func init() {
 
 	modified := find("vcs.modified")
 	if modified == "true" && os.Getenv("GOTKIT_OVERRIDE_DEVEL") == "" {
-		isDevel = true
+		isDevel.Store(true)
 	}
 }
 
 // IsDevel returns true if the windows spawned using app will have the .devel
-// class. This returns true if Go reports that vcs.modified is true.
-func IsDevel() bool { return isDevel }
+// class. This returns true if Go reports that vcs.modified is true, if the
+// GOTKIT_DEVEL environment variable is set to a true value, or if SetDevel has
+// been called with true.
+func IsDevel() bool { return isDevel.Load() }
+
+// SetDevel sets whether the application is in development mode, overriding
+// the default detected from the build information and the GOTKIT_DEVEL
+// environment variable. Only windows created after the call are affected.
+func SetDevel(devel bool) {
+	isDevel.Store(devel)
+	develSet.Store(true)
+}
+
+// buildVersion returns the main module's version as reported by Go. It returns
+// "(devel)" if the version is unknown.
+func buildVersion() string {
+	build, ok := debug.ReadBuildInfo()
+	if !ok || build.Main.Version == "" {
+		return "(devel)"
+	}
+	return build.Main.Version
+}
 
 /*
 	_diamondburned_ — Today at 16:52
@@ -71,11 +101,16 @@ func Hook(f func(*Application)) {
 // Application describes the state of a Matrix application.
 type Application struct {
 	*gtk.Application
-	ctx  context.Context // non-nil if Run
+	ctx  context.Context // see Context
 	name string
 
-	configPath lazyString
-	cacheDir   lazyString
+	version        string
+	versionInTitle bool
+
+	shutdownHooks []func()
+
+	configPath *lazyString
+	cacheDir   *lazyString
 }
 
 type ctxKey uint
@@ -114,12 +149,16 @@ func IsActive(ctx context.Context) bool {
 	return false
 }
 
-// New creates a new Application.
+// New creates a new Application. The given context is the base of the
+// Application's context: cancelling it cancels Context, and Run derives its
+// context from it. ctx must not be nil; use context.Background() if there's no
+// parent context.
 func New(ctx context.Context, appID, appName string) *Application {
 	return NewWithFlags(ctx, appID, appName, gio.ApplicationFlagsNone)
 }
 
-// NewWithFlags creates a new Application with the given application flags.
+// NewWithFlags creates a new Application with the given application flags. See
+// New for how ctx is used.
 func NewWithFlags(ctx context.Context, appID, appName string, flags gio.ApplicationFlags) *Application {
 	if ctx == nil {
 		panic("app: given ctx is nil")
@@ -128,11 +167,17 @@ func NewWithFlags(ctx context.Context, appID, appName string, flags gio.Applicat
 	app := &Application{
 		Application: gtk.NewApplication(appID, flags),
 		name:        appName,
+		version:     buildVersion(),
 	}
 
 	ctx, cancel := context.WithCancel(ctx)
-	app.ctx = ctx
-	app.Application.ConnectShutdown(cancel)
+	app.ctx = context.WithValue(ctx, applicationKey, app)
+	app.Application.ConnectShutdown(func() {
+		// Run the hooks before cancelling so that they can still use the
+		// context.
+		app.runShutdownHooks()
+		cancel()
+	})
 
 	app.Application.ConnectStartup(func() {
 		// TODO: make this display-bound. gtkutil has code for that.
@@ -147,13 +192,7 @@ func NewWithFlags(ctx context.Context, appID, appName string, flags gio.Applicat
 			log.Println("cannot get user cache directory; falling back to", d)
 		}
 
-		cacheDir := filepath.Join(d, app.BaseID())
-
-		if err := os.MkdirAll(cacheDir, 0755); err != nil {
-			log.Println("error making config dir:", err)
-		}
-
-		return cacheDir
+		return makeDir(filepath.Join(d, app.BaseID()))
 	})
 
 	app.configPath = newLazyString(func() string {
@@ -162,16 +201,13 @@ func NewWithFlags(ctx context.Context, appID, appName string, flags gio.Applicat
 			log.Fatalln("failed to get user config dir:", err)
 		}
 
-		configPath := filepath.Join(d, app.BaseID())
-
-		// Enforce the right permissions.
-		if err := os.MkdirAll(configPath, 0755); err != nil {
-			log.Println("error making config dir:", err)
-		}
-
-		return configPath
+		return makeDir(filepath.Join(d, app.BaseID()))
 	})
 
+	// Registered first so that it runs last, after other hooks had the chance
+	// to save their state.
+	app.OnShutdown(flushStates)
+
 	for _, hook := range appHooks {
 		hook(app)
 	}
@@ -243,14 +279,39 @@ func filterAndLogErrors(prefix string, errors []error) []error {
 	return nonNils
 }
 
-// Context returns the Application's context. This context contains the
-// Application instance and will be cancelled on SIGINT once Run has been
-// called. This method will panic if it's called before Run is called. Use it
-// during initialization within signals.
+// Context returns the Application's context. It is never nil. The context
+// contains the Application instance and is cancelled when the application
+// shuts down. Before Run is called, it is derived from the context given to
+// New, so it's not yet cancelled on SIGINT; contexts taken before Run don't
+// get that cancellation either.
 func (app *Application) Context() context.Context {
+	if app.ctx == nil {
+		return context.Background()
+	}
 	return app.ctx
 }
 
+// OnShutdown registers f to be called when the application shuts down. The
+// callbacks are called on the main thread in LIFO order, before the
+// Application's context is cancelled. Use it for ordered cleanup that must
+// finish before the application exits, such as flushing pending writes.
+//
+// This differs from watching the context: context cancellation is
+// asynchronous and unordered, so there is no guarantee that a goroutine waiting
+// on ctx.Done() gets to run before the process exits.
+func (app *Application) OnShutdown(f func()) {
+	app.shutdownHooks = append(app.shutdownHooks, f)
+}
+
+func (app *Application) runShutdownHooks() {
+	hooks := app.shutdownHooks
+	app.shutdownHooks = nil
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
+
 // Quit quits the application. The function is thread-safe.
 func (app *Application) Quit() {
 	glib.IdleAddPriority(coreglib.PriorityHigh, app.Application.Quit)
@@ -290,6 +351,7 @@ func (app *Application) AddActionShortcuts(m map[string]string) {
 			action = "app." + action
 		}
 		actionAccels[action] = append(actionAccels[action], accel)
+		gtkutil.RecordShortcut(accel)
 	}
 
 	for action, accels := range actionAccels {
@@ -330,6 +392,25 @@ func (app *Application) AddActionShortcuts(m map[string]string) {
 
 }
 
+// AddDescribedActionShortcuts is like AddActionShortcuts, except each shortcut
+// is also described under the given section, so it's listed in the shortcuts
+// window and the command palette.
+func (app *Application) AddDescribedActionShortcuts(section locale.Localized, shortcuts []gtkutil.Shortcut) {
+	actions := make(map[string]string, len(shortcuts))
+	described := make([]gtkutil.Shortcut, len(shortcuts))
+
+	for i, shortcut := range shortcuts {
+		if !strings.HasPrefix(shortcut.Action, "app.") {
+			shortcut.Action = "app." + shortcut.Action
+		}
+		actions[shortcut.Accel] = shortcut.Action
+		described[i] = shortcut
+	}
+
+	app.AddActionShortcuts(actions)
+	gtkutil.DescribeActionShortcuts(section, described)
+}
+
 // AddActions adds the given map of actions into the Application.
 func (app *Application) AddActions(m map[string]func()) {
 	for name, fn := range m {
@@ -399,13 +480,48 @@ func (app *Application) Name() string {
 	return app.name
 }
 
+// Version returns the application version. By default, this is the main
+// module's version as reported by Go's build information, or "(devel)" if it
+// is unknown. Use SetVersion to override it.
+func (app *Application) Version() string {
+	return app.version
+}
+
+// SetVersion overrides the application version. This is useful if the
+// application is versioned through other means, such as ldflags. It should
+// be called before any window is created.
+func (app *Application) SetVersion(version string) {
+	app.version = version
+}
+
+// SetVersionInTitle sets whether SuffixedTitle should also include the
+// application version. It is false by default.
+func (app *Application) SetVersionInTitle(versionInTitle bool) {
+	app.versionInTitle = versionInTitle
+}
+
+// IsDevel returns true if the windows spawned using app will have the .devel
+// class. It is true if IsDevel is true or if the application version is marked
+// as modified ("+dirty"), unless SetDevel was explicitly called.
+func (app *Application) IsDevel() bool {
+	if develSet.Load() {
+		return isDevel.Load()
+	}
+	return isDevel.Load() || strings.HasSuffix(app.version, "+dirty")
+}
+
 // SuffixedTitle suffixes the title with the application name and returns the
-// string.
+// string. If SetVersionInTitle is true, then the version is also appended.
 func (app *Application) SuffixedTitle(title string) string {
+	name := app.name
+	if app.versionInTitle && app.version != "" {
+		name += " " + app.version
+	}
+
 	if title == "" {
-		return app.name
+		return name
 	}
-	return title + " — " + app.name
+	return title + " — " + name
 }
 
 // ConfigPath returns the path to the configuration directory with the given
@@ -421,6 +537,30 @@ func (app *Application) CachePath(tails ...string) string {
 	return joinTails(app.cacheDir.v(), tails)
 }
 
+// SetConfigDir overrides the configuration directory returned by ConfigPath,
+// which is normally the application's directory inside os.UserConfigDir. This
+// is useful for tests and portable installs. It must be called before
+// ConfigPath is first used.
+func (app *Application) SetConfigDir(dir string) {
+	app.configPath = newLazyString(func() string { return makeDir(dir) })
+}
+
+// SetCacheDir overrides the cache directory returned by CachePath, which is
+// normally the application's directory inside os.UserCacheDir. Like
+// SetConfigDir, it must be called before CachePath is first used.
+func (app *Application) SetCacheDir(dir string) {
+	app.cacheDir = newLazyString(func() string { return makeDir(dir) })
+}
+
+// makeDir creates dir if it doesn't exist yet and returns it.
+func makeDir(dir string) string {
+	// Enforce the right permissions.
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Println("error making directory:", err)
+	}
+	return dir
+}
+
 func joinTails(dir string, tails []string) string {
 	if len(tails) == 1 {
 		dir = filepath.Join(dir, tails[0])
@@ -438,11 +578,11 @@ type lazyString struct {
 	once sync.Once
 }
 
-func newLazyString(f func() string) lazyString {
-	return lazyString{fun: f}
+func newLazyString(f func() string) *lazyString {
+	return &lazyString{fun: f}
 }
 
-func (l lazyString) v() string {
+func (l *lazyString) v() string {
 	l.once.Do(func() {
 		l.str = l.fun()
 	})
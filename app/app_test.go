@@ -0,0 +1,30 @@
+package app
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLazyString(t *testing.T) {
+	var calls int
+	l := newLazyString(func() string {
+		calls++
+		return "value"
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v := l.v(); v != "value" {
+				t.Errorf("unexpected value %q", v)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("expected fun to be called once, got %d calls", calls)
+	}
+}
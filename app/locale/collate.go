@@ -0,0 +1,76 @@
+package locale
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+var collator struct {
+	sync.Mutex
+	lang string
+	c    *collate.Collator
+}
+
+// Collator returns the collator for the current locale, which compares strings
+// the way users of the locale expect them to be sorted. The collator is cached
+// and rebuilt when the locale changes. It must not be used concurrently; use
+// SortStrings or CompareStrings for that.
+func Collator() *collate.Collator {
+	collator.Lock()
+	defer collator.Unlock()
+
+	return currentCollator()
+}
+
+// currentCollator returns the cached collator. collator must be locked.
+func currentCollator() *collate.Collator {
+	lang := collatorLanguage()
+	if collator.c == nil || collator.lang != lang {
+		tag, err := language.Parse(lang)
+		if err != nil {
+			tag = language.Und
+		}
+		collator.lang = lang
+		collator.c = collate.New(tag, collate.Loose)
+	}
+	return collator.c
+}
+
+// collatorLanguage returns the language to collate with. If the current locale
+// has no translations, then the user's preferred language is used.
+func collatorLanguage() string {
+	lang := current.GetLanguage()
+	if lang == "" || lang == "C" {
+		if names := glib.GetLanguageNames(); len(names) > 0 {
+			lang = names[0]
+		}
+	}
+
+	// Turn POSIX locale names like en_US.UTF-8 into BCP 47 tags like en-US.
+	if i := strings.IndexAny(lang, ".@"); i != -1 {
+		lang = lang[:i]
+	}
+	return strings.ReplaceAll(lang, "_", "-")
+}
+
+// SortStrings sorts the given user-facing strings in place using the collator
+// for the current locale.
+func SortStrings(strs []string) {
+	collator.Lock()
+	defer collator.Unlock()
+
+	currentCollator().SortStrings(strs)
+}
+
+// CompareStrings compares the given user-facing strings using the collator for
+// the current locale. It returns -1, 0 or 1, like strings.Compare.
+func CompareStrings(a, b string) int {
+	collator.Lock()
+	defer collator.Unlock()
+
+	return currentCollator().CompareString(a, b)
+}
@@ -33,28 +33,67 @@ const (
 	relativeDefault   = "%X %x"
 )
 
-// TimeAgo formats a long string that expresses the relative time difference
-// from now until t.
-func TimeAgo(timestamp time.Time) string {
-	timestamp = timestamp.Local()
+// Truncator is a rule that TimeAgo uses to format a timestamp.
+type Truncator struct {
+	// Match returns true if the rule applies to the timestamp t at the
+	// current time now. Both times are in the local timezone.
+	Match func(t, now time.Time) bool
+	// Format is the GLib DateTime format used to render the timestamp, such
+	// as "%A at %X". It is localized using the gotkit domain.
+	Format string
+	// Render, if not nil, is used to render the timestamp instead of Format.
+	// This allows for rules such as "5 minutes ago".
+	Render func(t, now time.Time) string
+}
 
-	tts := timestamp
-	now := time.Now().Local()
+// TimeAgoTruncators are the rules used by TimeAgo. The first rule that matches
+// is used. If no rules match, then the timestamp is rendered using both its
+// date and time. Apps may change this to customize TimeAgo, but only during
+// initialization. By default, it renders timestamps of today, yesterday and
+// this week relative to now.
+var TimeAgoTruncators = []Truncator{
+	{Match: MatchDaysAgo(0), Format: relativeToday},
+	{Match: MatchDaysAgo(1), Format: relativeYesterday},
+	{Match: MatchSameWeek, Format: relativeWeek},
+}
 
-	ttsDay := truncateDay(tts)
-	nowDay := truncateDay(now)
-	if ttsDay.Equal(nowDay) {
-		return renderTime(timestamp, relativeToday)
-	}
+// MatchWithin returns a Truncator matcher that matches timestamps less than d
+// before now.
+func MatchWithin(d time.Duration) func(t, now time.Time) bool {
+	return func(t, now time.Time) bool { return now.Sub(t) < d }
+}
 
-	if ttsDay.Equal(truncateDay(now.Add(-Day))) {
-		return renderTime(timestamp, relativeYesterday)
+// MatchDaysAgo returns a Truncator matcher that matches timestamps on the day
+// that is n days before now, so 0 matches today and 1 matches yesterday. Days
+// start at midnight in the local timezone.
+func MatchDaysAgo(n int) func(t, now time.Time) bool {
+	return func(t, now time.Time) bool {
+		y, m, d := now.Date()
+		day := time.Date(y, m, d-n, 0, 0, 0, 0, now.Location())
+		return truncateDay(t).Equal(day)
 	}
+}
+
+// MatchSameWeek is a Truncator matcher that matches timestamps in the same
+// week as now. Weeks start on Sunday at midnight in the local timezone.
+func MatchSameWeek(t, now time.Time) bool {
+	return truncateWeek(t).Equal(truncateWeek(now))
+}
+
+// TimeAgo formats a long string that expresses the relative time difference
+// from now until t. See TimeAgoTruncators.
+func TimeAgo(timestamp time.Time) string {
+	timestamp = timestamp.Local()
+	now := time.Now().Local()
 
-	ttsWeek := truncateWeek(tts)
-	nowWeek := truncateWeek(now)
-	if ttsWeek.Equal(nowWeek) {
-		return renderTime(timestamp, relativeWeek)
+	for _, truncator := range TimeAgoTruncators {
+		if !truncator.Match(timestamp, now) {
+			continue
+		}
+		if truncator.Render != nil {
+			return truncator.Render(timestamp, now)
+		}
+		return renderTime(timestamp, truncator.Format)
 	}
 
 	return renderTime(timestamp, relativeDefault)
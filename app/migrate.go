@@ -0,0 +1,123 @@
+package app
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// MigrateFrom moves the configuration and cache directories of an application
+// with the given old base ID into this application's directories, e.g. for an
+// application that was forked from or renamed from another one. It should be
+// called early, before any configuration is read.
+//
+// Directories are merged: files that already exist in the new directories are
+// kept and the old ones are left in place. The old directories are removed
+// once everything has been moved out of them. Calling MigrateFrom again once
+// the migration is done does nothing, so it's safe to call on every run.
+func (app *Application) MigrateFrom(oldBaseID string) {
+	if oldBaseID == "" || oldBaseID == app.BaseID() {
+		return
+	}
+
+	if d, err := os.UserConfigDir(); err == nil {
+		migrateDir(filepath.Join(d, oldBaseID), app.ConfigPath())
+	}
+	if d, err := os.UserCacheDir(); err == nil {
+		migrateDir(filepath.Join(d, oldBaseID), app.CachePath())
+	}
+}
+
+// migrateDir merges the old directory into dst, then removes whatever empty
+// directories are left of old.
+func migrateDir(old, dst string) {
+	if _, err := os.Stat(old); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn(
+				"cannot stat old directory to migrate",
+				"module", "app",
+				"path", old,
+				"err", err)
+		}
+		return
+	}
+
+	slog.Info(
+		"migrating old directory",
+		"module", "app",
+		"from", old,
+		"to", dst)
+
+	mergeDir(old, dst)
+	removeEmptyDirs(old)
+}
+
+// mergeDir moves the entries of src into dst. Entries that don't exist in dst
+// are renamed over. Directories that exist in both are merged recursively.
+func mergeDir(src, dst string) {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		slog.Warn(
+			"cannot read old directory to migrate",
+			"module", "app",
+			"path", src,
+			"err", err)
+		return
+	}
+
+	for _, entry := range entries {
+		srcPath := filepath.Join(src, entry.Name())
+		dstPath := filepath.Join(dst, entry.Name())
+
+		dstStat, err := os.Lstat(dstPath)
+		if err == nil {
+			if entry.IsDir() && dstStat.IsDir() {
+				mergeDir(srcPath, dstPath)
+				continue
+			}
+
+			slog.Warn(
+				"not migrating old file since the new one already exists",
+				"module", "app",
+				"from", srcPath,
+				"to", dstPath)
+			continue
+		}
+
+		if err := os.Rename(srcPath, dstPath); err != nil {
+			slog.Warn(
+				"cannot migrate old file",
+				"module", "app",
+				"from", srcPath,
+				"to", dstPath,
+				"err", err)
+			continue
+		}
+
+		slog.Debug(
+			"migrated old file",
+			"module", "app",
+			"from", srcPath,
+			"to", dstPath)
+	}
+}
+
+// removeEmptyDirs removes dir and all of its subdirectories that are empty
+// after their own empty subdirectories are removed.
+func removeEmptyDirs(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			removeEmptyDirs(filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	// os.Remove fails on non-empty directories, which are kept on purpose.
+	os.Remove(dir)
+}
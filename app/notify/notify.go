@@ -4,9 +4,11 @@ package notify
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 
 	"github.com/diamondburned/gotk4/pkg/gdkpixbuf/v2"
 	"github.com/diamondburned/gotk4/pkg/gio/v2"
@@ -40,9 +42,60 @@ func (n iconName) icon() gio.Iconner {
 }
 
 // MaxIconSize is the maximum size of the notification icon to give to
-// the gio.Icon.
+// the gio.Icon. It is the default size used by IconURL.
 const MaxIconSize = 64
 
+// maxCachedIcons is the maximum number of icons kept in iconURLCache.
+const maxCachedIcons = 128
+
+type iconCacheKey struct {
+	url  string
+	size int
+}
+
+var iconURLCache struct {
+	sync.Mutex
+	icons map[iconCacheKey]*gio.BytesIcon
+}
+
+func init() { imgutil.OnTrimMemory(trimIconURLCache) }
+
+// trimIconURLCache drops all cached icons. They are loaded again from the disk
+// cache when needed.
+func trimIconURLCache() {
+	iconURLCache.Lock()
+	defer iconURLCache.Unlock()
+
+	iconURLCache.icons = nil
+}
+
+func cachedIconURL(k iconCacheKey) *gio.BytesIcon {
+	iconURLCache.Lock()
+	defer iconURLCache.Unlock()
+
+	return iconURLCache.icons[k]
+}
+
+func cacheIconURL(k iconCacheKey, icon *gio.BytesIcon) {
+	iconURLCache.Lock()
+	defer iconURLCache.Unlock()
+
+	if iconURLCache.icons == nil {
+		iconURLCache.icons = make(map[iconCacheKey]*gio.BytesIcon)
+	}
+
+	if len(iconURLCache.icons) >= maxCachedIcons {
+		// Evict an arbitrary icon. We don't need anything fancier than this,
+		// since the images are still cached on disk by imgutil.
+		for k := range iconURLCache.icons {
+			delete(iconURLCache.icons, k)
+			break
+		}
+	}
+
+	iconURLCache.icons[k] = icon
+}
+
 type iconURL struct {
 	fallbackIcon Icon
 	loadingIcon  <-chan *gio.BytesIcon
@@ -50,28 +103,50 @@ type iconURL struct {
 }
 
 // IconURL creates a notification icon that is an image fetched online. The
-// image is fetched using imgutil.GETPixbuf.
+// image is fetched using imgutil.GET and rescaled to MaxIconSize.
 func IconURL(ctx context.Context, url string, fallback Icon) Icon {
+	return IconURLSized(ctx, url, MaxIconSize, fallback)
+}
+
+// IconURLSized is like IconURL, except the image is rescaled to fit within the
+// given size instead of MaxIconSize. Icons are cached by URL and size, so
+// repeated notifications using the same icon won't refetch it.
+func IconURLSized(ctx context.Context, url string, size int, fallback Icon) Icon {
 	if url == "" {
 		return fallback
 	}
 
+	if size <= 0 {
+		size = MaxIconSize
+	}
+
+	key := iconCacheKey{url, size}
+	if icon := cachedIconURL(key); icon != nil {
+		return iconURL{
+			fallbackIcon: fallback,
+			finishedIcon: icon,
+		}
+	}
+
 	loadingIcon := make(chan *gio.BytesIcon, 1)
 	go func() {
 		ctx := imgutil.WithOpts(ctx,
-			imgutil.WithRescale(MaxIconSize, MaxIconSize),
+			imgutil.WithMaxSize(size, size),
 			imgutil.WithDoneFn(func(error) { close(loadingIcon) }),
 		)
 
 		imgutil.GET(ctx, url, imgutil.ImageSetter{
 			SetFromPixbuf: func(p *gdkpixbuf.Pixbuf) {
-				b, err := p.SaveToBufferv("png", []string{"compression"}, []string{"0"})
+				b, err := imgutil.EncodePNG(p)
 				if err != nil {
-					log.Println("cannot save notification icon URL as PNG:", err)
+					log.Println("cannot save notification icon URL:", err)
 					return
 				}
 
-				loadingIcon <- gio.NewBytesIcon(glib.NewBytesWithGo(b))
+				icon := gio.NewBytesIcon(glib.NewBytesWithGo(b))
+				cacheIconURL(key, icon)
+
+				loadingIcon <- icon
 			},
 		})
 	}()
@@ -83,7 +158,7 @@ func IconURL(ctx context.Context, url string, fallback Icon) Icon {
 }
 
 func (n iconURL) async() bool {
-	return true
+	return n.finishedIcon == nil
 }
 
 func (n iconURL) icon() gio.Iconner {
@@ -158,6 +233,27 @@ type Notification struct {
 	// Sound, if true, will ring a sound. If it's an empty string, then no sound
 	// is played.
 	Sound Sound
+	// Category is the optional notification category, such as "im.received"
+	// or "email.arrived". Notification servers that support categories may use
+	// it to group notifications or pick the right feedback. It is a no-op on
+	// servers that don't support it.
+	//
+	// See the freedesktop notification specification for the list of standard
+	// categories.
+	Category string
+	// Thread is an optional app-defined key for the conversation that the
+	// notification belongs to, such as a room ID. If ID is empty, then Thread
+	// is used as the notification ID, so newer notifications in the same
+	// thread replace older ones instead of piling up.
+	Thread string
+}
+
+// id returns the ID to send the notification with.
+func (n *Notification) id() string {
+	if n.ID == "" && n.Thread != "" {
+		return string(HashID("thread", n.Thread))
+	}
+	return string(n.ID)
 }
 
 // async returns true if the notification must be constructed within a
@@ -166,9 +262,23 @@ func (n *Notification) async() bool {
 	return (n.Icon != nil && n.Icon.async())
 }
 
-func (n *Notification) asGio() *gio.Notification {
+// ErrMissingTitle is returned when sending a Notification without a Title.
+var ErrMissingTitle = errors.New("notification missing Title")
+
+// ErrNoApplication is returned when sending a Notification without an
+// Application, such as when the given context doesn't have one.
+var ErrNoApplication = errors.New("notification has no Application to send to")
+
+func (n *Notification) validate() error {
 	if n.Title == "" {
-		panic("notification missing Title")
+		return ErrMissingTitle
+	}
+	return nil
+}
+
+func (n *Notification) asGio() (*gio.Notification, error) {
+	if err := n.validate(); err != nil {
+		return nil, err
 	}
 
 	notification := gio.NewNotification(n.Title)
@@ -181,6 +291,10 @@ func (n *Notification) asGio() *gio.Notification {
 		notification.SetPriority(n.Priority)
 	}
 
+	if n.Category != "" {
+		notification.SetCategory(n.Category)
+	}
+
 	if n.Icon != nil {
 		if icon := n.Icon.icon(); icon != nil {
 			notification.SetIcon(icon)
@@ -191,7 +305,7 @@ func (n *Notification) asGio() *gio.Notification {
 		notification.SetDefaultActionAndTarget(n.Action.ActionID, n.Action.Argument)
 	}
 
-	return notification
+	return notification, nil
 }
 
 // ShowNotification is a preference.
@@ -222,22 +336,62 @@ func (n *Notification) playSound(app *app.Application) {
 // Not making Send take in a context.Context is a fairly arbitrary decision that
 // is probably a bad idea in hindsight.
 
-// Send sends the notification to the application.
-func (n *Notification) Send(app *app.Application) {
+// Send sends the notification to the application. It is safe to call Send from
+// any thread: the notification is always handed to GIO from the main loop.
+//
+// An error is returned if the notification cannot be sent, such as when app is
+// nil or the notification has no Title. Notifications that are not sent
+// because the user disabled them do not return an error.
+func (n *Notification) Send(app *app.Application) error {
+	if app == nil {
+		return ErrNoApplication
+	}
+
+	if err := n.validate(); err != nil {
+		return err
+	}
+
 	if !ShowNotification.Value() {
-		return
+		return nil
 	}
 
 	n.playSound(app)
 
+	send := func() {
+		notification, err := n.asGio()
+		if err != nil {
+			log.Println("cannot create notification:", err)
+			return
+		}
+
+		glib.IdleAdd(func() { app.SendNotification(n.id(), notification) })
+	}
+
 	if n.async() {
-		go func() { app.SendNotification(string(n.ID), n.asGio()) }()
+		// Resolving the icon may block, so do it outside the main loop.
+		go send()
 	} else {
-		app.SendNotification(string(n.ID), n.asGio())
+		send()
 	}
+
+	return nil
+}
+
+// MustSend is like Send, except it panics if the notification cannot be sent.
+// It is meant for cases where failing to send is a programming error.
+func (n *Notification) MustSend(app *app.Application) {
+	if err := n.Send(app); err != nil {
+		panic(err)
+	}
+}
+
+// Send is a convenient function. Like (*Notification).Send, it can be called
+// from any thread.
+func Send(ctx context.Context, n Notification) error {
+	return n.Send(app.FromContext(ctx))
 }
 
-// Send is a convenient function.
-func Send(ctx context.Context, n Notification) {
-	n.Send(app.FromContext(ctx))
+// MustSend is a convenient function around (*Notification).MustSend.
+func MustSend(ctx context.Context, n Notification) {
+	n.MustSend(app.FromContext(ctx))
 }
@@ -0,0 +1,124 @@
+package prefs
+
+import (
+	"log"
+
+	"github.com/diamondburned/gotkit/app/locale"
+)
+
+// GroupMeta describes the metadata of a Group.
+type GroupMeta struct {
+	Name        locale.Localized
+	Section     locale.Localized
+	Description locale.Localized
+}
+
+// Group groups related properties within the same section, such as a "Proxy"
+// group with the host and port of the proxy. Grouped properties are shown
+// together under the group's name. The properties are still saved as
+// individual properties, so grouping existing properties doesn't change how
+// they're saved.
+type Group struct {
+	GroupMeta
+	// Toggle is the optional property that enables the rest of the group.
+	Toggle *Bool
+
+	props []Prop
+}
+
+// groupRegistry maps each grouped property to its group.
+var groupRegistry = map[Prop]*Group{}
+
+// NewGroup creates a new group of the given properties. If toggle is not nil,
+// then it is shown first, and the rest of the properties can only be changed
+// while it is true. All properties must be in the group's section, and each
+// property can only be in one group. This function should ideally be called
+// only during init.
+func NewGroup(meta GroupMeta, toggle *Bool, props ...Prop) *Group {
+	validateMeta(PropMeta{Name: meta.Name, Section: meta.Section})
+
+	g := &Group{
+		GroupMeta: meta,
+		Toggle:    toggle,
+	}
+
+	if toggle != nil {
+		g.props = append(g.props, toggle)
+	}
+	g.props = append(g.props, props...)
+
+	for _, prop := range g.props {
+		if prop.Meta().Section != meta.Section {
+			log.Panicf("prop %q is not in the section of group %q", prop.Meta().ID(), meta.Name)
+		}
+		if _, ok := groupRegistry[prop]; ok {
+			log.Panicf("prop %q is already in a group", prop.Meta().ID())
+		}
+		groupRegistry[prop] = g
+	}
+
+	return g
+}
+
+// Props returns the properties in the group in order, including Toggle.
+func (g *Group) Props() []Prop {
+	return g.props
+}
+
+// Enabled returns true if the group's properties can be changed, which is when
+// the group has no Toggle or the Toggle is true.
+func (g *Group) Enabled() bool {
+	return g.Toggle == nil || g.Toggle.Value()
+}
+
+// LocalizedGroup wraps Group and localizes its name and description. The
+// properties of the same group returned from one ListProperties call share the
+// same LocalizedGroup.
+type LocalizedGroup struct {
+	*Group
+	Name        string
+	Description string
+}
+
+// groupProps reorders the given properties so that the properties in the same
+// group are placed together in the group's order, at where the first of them
+// was.
+func groupProps(props []LocalizedProp, localize func(locale.Localized) string) []LocalizedProp {
+	groups := make(map[*Group]*LocalizedGroup)
+	byProp := make(map[Prop]LocalizedProp, len(props))
+
+	for _, prop := range props {
+		byProp[prop.Prop] = prop
+	}
+
+	grouped := make([]LocalizedProp, 0, len(props))
+
+	for _, prop := range props {
+		group, ok := groupRegistry[prop.Prop]
+		if !ok {
+			grouped = append(grouped, prop)
+			continue
+		}
+
+		if _, done := groups[group]; done {
+			continue
+		}
+
+		localized := &LocalizedGroup{
+			Group:       group,
+			Name:        localize(group.Name),
+			Description: localize(group.Description),
+		}
+		groups[group] = localized
+
+		for _, member := range group.props {
+			// Hidden properties aren't listed.
+			if prop, ok := byProp[member]; ok {
+				prop.Group = localized
+				grouped = append(grouped, prop)
+			}
+		}
+	}
+
+	return grouped
+}
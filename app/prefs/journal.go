@@ -0,0 +1,158 @@
+package prefs
+
+import (
+	"reflect"
+)
+
+// AnyProp is a Prop whose value can be read and published without knowing its
+// type.
+type AnyProp interface {
+	Prop
+	// AnyValue returns the current value.
+	AnyValue() any
+	// AnyPublish publishes the given value. ErrInvalidAnyType is returned if
+	// the value has the wrong type.
+	AnyPublish(any) error
+}
+
+// JournalEntry is a single change recorded by a Journal.
+type JournalEntry struct {
+	ID  ID
+	Old any
+	New any
+}
+
+// Journal records the changes made to all properties that implement AnyProp,
+// so they can be undone and redone. It is useful for an Undo action in a
+// preferences dialog. All methods must be called in the main thread.
+type Journal struct {
+	entries []JournalEntry
+	// applied is the number of entries that are applied, i.e. not undone.
+	applied int
+	max     int
+
+	last      map[ID]any
+	unsubs    []func()
+	replaying bool
+}
+
+// DefaultJournalSize is the default maximum number of changes kept by a
+// Journal.
+const DefaultJournalSize = 50
+
+// NewJournal creates a new Journal that keeps at most max changes, dropping
+// the oldest ones. If max is 0, then DefaultJournalSize is used. The Journal
+// only records changes to properties registered before it's created, so it
+// should be created after initialization.
+func NewJournal(max int) *Journal {
+	if max <= 0 {
+		max = DefaultJournalSize
+	}
+
+	j := &Journal{
+		max:  max,
+		last: make(map[ID]any, len(propRegistry)),
+	}
+
+	for id, prop := range propRegistry {
+		prop, ok := prop.(AnyProp)
+		if !ok {
+			continue
+		}
+
+		id := id
+		j.last[id] = prop.AnyValue()
+		j.unsubs = append(j.unsubs, prop.Pubsubber().Subscribe(func() {
+			j.record(id, prop.AnyValue())
+		}))
+	}
+
+	return j
+}
+
+// Close stops recording changes.
+func (j *Journal) Close() {
+	for _, unsub := range j.unsubs {
+		unsub()
+	}
+	j.unsubs = nil
+}
+
+func (j *Journal) record(id ID, v any) {
+	old := j.last[id]
+	if reflect.DeepEqual(old, v) {
+		return
+	}
+	j.last[id] = v
+
+	if j.replaying {
+		return
+	}
+
+	// Recording a new change drops everything that was undone.
+	j.entries = append(j.entries[:j.applied], JournalEntry{
+		ID:  id,
+		Old: old,
+		New: v,
+	})
+
+	if len(j.entries) > j.max {
+		j.entries = append(j.entries[:0], j.entries[len(j.entries)-j.max:]...)
+	}
+
+	j.applied = len(j.entries)
+}
+
+// CanUndo returns true if there is a change to undo.
+func (j *Journal) CanUndo() bool {
+	return j.applied > 0
+}
+
+// CanRedo returns true if there is an undone change to redo.
+func (j *Journal) CanRedo() bool {
+	return j.applied < len(j.entries)
+}
+
+// Undo reverts the last change. False is returned if there's nothing to undo
+// or if the old value cannot be published.
+func (j *Journal) Undo() bool {
+	if !j.CanUndo() {
+		return false
+	}
+
+	entry := j.entries[j.applied-1]
+	if !j.replay(entry.ID, entry.Old) {
+		return false
+	}
+
+	j.applied--
+	return true
+}
+
+// Redo reapplies the last undone change. False is returned if there's nothing
+// to redo or if the new value cannot be published.
+func (j *Journal) Redo() bool {
+	if !j.CanRedo() {
+		return false
+	}
+
+	entry := j.entries[j.applied]
+	if !j.replay(entry.ID, entry.New) {
+		return false
+	}
+
+	j.applied++
+	return true
+}
+
+func (j *Journal) replay(id ID, v any) bool {
+	prop, ok := propRegistry[id].(AnyProp)
+	if !ok {
+		return false
+	}
+
+	j.replaying = true
+	defer func() { j.replaying = false }()
+
+	return prop.AnyPublish(v) == nil
+}
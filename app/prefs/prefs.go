@@ -4,14 +4,18 @@ package prefs
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"go/doc"
 	"log"
+	"log/slog"
 	"math"
 	"os"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/diamondburned/gotkit/app"
 	"github.com/diamondburned/gotkit/app/locale"
@@ -33,6 +37,64 @@ func RegisterProp(p Prop) {
 	}
 
 	propRegistry[id] = p
+
+	anyChangeHooks.Lock()
+	defer anyChangeHooks.Unlock()
+
+	for hook := range anyChangeHooks.hooks {
+		hook.subscribe(id, p)
+	}
+}
+
+type anyChangeHook struct {
+	f      func(ID)
+	unsubs []func()
+}
+
+var anyChangeHooks = struct {
+	sync.Mutex
+	hooks map[*anyChangeHook]struct{}
+}{
+	hooks: map[*anyChangeHook]struct{}{},
+}
+
+// OnAnyChange calls f with the ID of any property whenever it changes,
+// including properties that are registered after OnAnyChange is called. f is
+// always called on the main thread. It is useful for e.g. marking the settings
+// as modified or triggering a sync. OnAnyChange must not be called during init.
+func OnAnyChange(f func(id ID)) (unsub func()) {
+	hook := &anyChangeHook{f: f}
+
+	anyChangeHooks.Lock()
+	for id, prop := range propRegistry {
+		hook.subscribe(id, prop)
+	}
+	anyChangeHooks.hooks[hook] = struct{}{}
+	anyChangeHooks.Unlock()
+
+	return func() {
+		anyChangeHooks.Lock()
+		delete(anyChangeHooks.hooks, hook)
+		unsubs := hook.unsubs
+		hook.unsubs = nil
+		anyChangeHooks.Unlock()
+
+		for _, unsub := range unsubs {
+			unsub()
+		}
+	}
+}
+
+func (h *anyChangeHook) subscribe(id ID, prop Prop) {
+	// Subscribe calls the callback once right away, which isn't a change.
+	subscribed := false
+	h.unsubs = append(h.unsubs, prop.Pubsubber().Subscribe(func() {
+		if !subscribed {
+			subscribed = true
+			return
+		}
+		h.f(id)
+	}))
 }
 
 // propOrder maps English prop names to the order integer.
@@ -109,7 +171,9 @@ func sectionPropOrder(orders propOrder, i, j string) bool {
 }
 
 // LoadData loads the given JSON data (usually returned from ReadSavedData)
-// directly into the global preference values.
+// directly into the global preference values. Properties that fail to load are
+// skipped and keep their current values, while the rest are still loaded. The
+// errors of all skipped properties are joined and returned.
 func LoadData(data []byte) error {
 	if len(data) == 0 {
 		return nil
@@ -118,16 +182,26 @@ func LoadData(data []byte) error {
 	if err := json.Unmarshal(data, &props); err != nil {
 		return err
 	}
+
+	var errs []error
 	for k, blob := range props {
 		prop, ok := propRegistry[ID(k)]
 		if !ok {
 			continue
 		}
 		if err := prop.UnmarshalJSON(blob); err != nil {
-			return fmt.Errorf("error at %s: %w", k, err)
+			slog.Warn(
+				"cannot load saved preference, skipping",
+				"module", "prefs",
+				"id", k,
+				"err", err)
+			errs = append(errs, fmt.Errorf("error at %s: %w", k, err))
 		}
 	}
-	return nil
+
+	// Sort the errors, since map iteration order is random.
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return stderrors.Join(errs...)
 }
 
 // Snapshot describes a snapshot of the preferences state.
@@ -162,9 +236,44 @@ func prefsPath(ctx context.Context) string {
 	return app.FromContext(ctx).ConfigPath("prefs.json")
 }
 
-// Save atomically saves the snapshot to file.
+// lastSaved keeps the hash of the last data written to or read from each
+// prefs.json path, so saving an unchanged snapshot doesn't rewrite the file.
+var lastSaved = struct {
+	sync.Mutex
+	hashes map[string][sha256.Size]byte
+}{
+	hashes: make(map[string][sha256.Size]byte),
+}
+
+func setLastSaved(path string, b []byte) {
+	lastSaved.Lock()
+	lastSaved.hashes[path] = sha256.Sum256(b)
+	lastSaved.Unlock()
+}
+
+// Save atomically saves the snapshot to file. The file is not rewritten if it
+// already contains the same snapshot.
 func (s Snapshot) Save(ctx context.Context) error {
-	return config.WriteFile(prefsPath(ctx), s.JSON())
+	path := prefsPath(ctx)
+	data := s.JSON()
+	hash := sha256.Sum256(data)
+
+	lastSaved.Lock()
+	defer lastSaved.Unlock()
+
+	if last, ok := lastSaved.hashes[path]; ok && last == hash {
+		// Still write the file if it was deleted from under us.
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+	}
+
+	if err := config.WriteFile(path, data); err != nil {
+		return err
+	}
+
+	lastSaved.hashes[path] = hash
+	return nil
 }
 
 // AsyncLoadSaved asynchronously loads the saved preferences.
@@ -186,7 +295,9 @@ func AsyncLoadSaved(ctx context.Context, done func(error)) {
 		return func() {
 			err := LoadData(data)
 			if err != nil {
-				err = errors.Wrap(err, "cannot load saved preferences")
+				// Only some of the preferences may have failed to load, so
+				// don't imply that all of them are lost.
+				err = errors.Wrap(err, "some saved preferences could not be loaded")
 			}
 			onDone(err)
 		}
@@ -197,7 +308,9 @@ func AsyncLoadSaved(ctx context.Context, done func(error)) {
 // Users should give the returned byte slice to LoadData. A nil byte slice is a
 // valid value.
 func ReadSavedData(ctx context.Context) ([]byte, error) {
-	b, err := os.ReadFile(prefsPath(ctx))
+	path := prefsPath(ctx)
+
+	b, err := config.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, nil
@@ -205,6 +318,8 @@ func ReadSavedData(ctx context.Context) ([]byte, error) {
 		log.Println("cannot open prefs.json:", err)
 		return nil, err
 	}
+
+	setLastSaved(path, b)
 	return b, nil
 }
 
@@ -221,6 +336,8 @@ type LocalizedProp struct {
 	Prop
 	Name        string
 	Description string
+	// Group is the group that the property is in, or nil if it's not in any.
+	Group *LocalizedGroup
 }
 
 // ListProperties enumerates all known global properties into a map of
@@ -271,11 +388,13 @@ func ListProperties(ctx context.Context) []ListedSection {
 			return sectionPropOrder(orders, iname, jname)
 		})
 
+		section.Props = groupProps(section.Props, localize)
+
 		sections = append(sections, section)
 	}
 
 	sort.Slice(sections, func(i, j int) bool {
-		return sections[i].name < sections[j].name
+		return locale.CompareStrings(sections[i].Name, sections[j].Name) < 0
 	})
 
 	return sections
@@ -40,10 +40,11 @@ func (p *Pubsub) Publish() {
 	})
 }
 
-// SubscribeWidget subscribes the given widget and callback to changes. If rm is
-// called, then the subscription is removed. The given callback will be called
-// once in the receiving goroutine to signal a change. It is guaranteed for the
-// callback to only be consistently called on that goroutine.
+// SubscribeWidget subscribes the given widget and callback to changes. The
+// subscription lives only while the widget is mapped: f is subscribed using
+// Subscribe every time the widget is mapped, so it's called right away with the
+// current value, and it's unsubscribed when the widget is unmapped. Use
+// Subscribe for subscribers that aren't tied to a widget.
 func (p *Pubsub) SubscribeWidget(widget gtk.Widgetter, f func()) {
 	var unsub func()
 	w := gtk.BaseWidget(widget)
@@ -63,8 +64,13 @@ func (p *Pubsub) SubscribeWidget(widget gtk.Widgetter, f func()) {
 	})
 }
 
-// Subscribe adds f into the pubsub's subscription queue. f will always be
-// invoked in the main thread.
+// Subscribe adds f into the pubsub's subscription queue. f is called once
+// immediately so that it can apply the current value, then again on every
+// Publish until rm is called. f will always be invoked in the main thread.
+//
+// This is the usual way to subscribe outside of widgets; see SubscribeWidget
+// for subscriptions that follow a widget's lifetime, and SubscribeInit for
+// subscribing during init.
 func (p *Pubsub) Subscribe(f func()) (rm func()) {
 	b := &funcBox{f}
 
@@ -82,7 +88,8 @@ func (p *Pubsub) Subscribe(f func()) (rm func()) {
 
 // SubscribeInit is like Subscribe, except you can't unsubscribe, the callback
 // is not called, and the method is not thread-safe. It is only meant to be
-// called in init() functions.
+// called in init() functions, where the main loop isn't running yet, so
+// Subscribe would block forever waiting on it.
 func (p *Pubsub) SubscribeInit(f func()) {
 	b := &funcBox{f}
 	p.funcs[b] = struct{}{}
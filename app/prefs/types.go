@@ -12,8 +12,10 @@ import (
 	"sync"
 	"sync/atomic"
 
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil"
 )
 
 // ErrInvalidAnyType is returned by a preference property if it has the wrong
@@ -64,10 +66,10 @@ func (b *Bool) UnmarshalJSON(blob []byte) error {
 	return nil
 }
 
-// AnyValue implements Prop.
+// AnyValue implements AnyProp.
 func (b *Bool) AnyValue() interface{} { return b.Value() }
 
-// AnyPublish implements Prop.
+// AnyPublish implements AnyProp.
 func (b *Bool) AnyPublish(v interface{}) error {
 	bv, ok := v.(bool)
 	if !ok {
@@ -163,6 +165,19 @@ func (i *Int) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// AnyValue implements AnyProp.
+func (i *Int) AnyValue() interface{} { return i.Value() }
+
+// AnyPublish implements AnyProp.
+func (i *Int) AnyPublish(v interface{}) error {
+	iv, ok := v.(int)
+	if !ok {
+		return ErrInvalidAnyType
+	}
+	i.Publish(iv)
+	return nil
+}
+
 // CreateWidget creates either a *gtk.Scale or a *gtk.SpinButton.
 func (i *Int) CreateWidget(ctx context.Context, save func()) gtk.Widgetter {
 	min := float64(i.Min)
@@ -193,6 +208,37 @@ func (i *Int) CreateWidget(ctx context.Context, save func()) gtk.Widgetter {
 // WidgetIsLarge is true if Slider is true.
 func (i *Int) WidgetIsLarge() bool { return i.Slider }
 
+// BindAdjustmentToInt binds adj and p both ways: adj is set to p's value
+// whenever p changes, and p is published whenever adj's value is changed, such
+// as by a slider. This lets widgets outside of prefui share the property's
+// state. Changes made through adj are not saved; the caller should save the
+// preferences itself if needed. The returned function removes the binding. It
+// must be called on the main thread.
+func BindAdjustmentToInt(adj *gtk.Adjustment, p *Int) (unbind func()) {
+	// paused prevents adj from publishing the value that it was just set to.
+	var paused bool
+
+	handle := adj.ConnectValueChanged(func() {
+		if paused {
+			return
+		}
+		if v := int(math.Round(adj.Value())); v != p.Value() {
+			p.Publish(v)
+		}
+	})
+
+	unsub := p.Subscribe(func() {
+		paused = true
+		adj.SetValue(float64(p.Value()))
+		paused = false
+	})
+
+	return func() {
+		unsub()
+		adj.HandlerDisconnect(handle)
+	}
+}
+
 // StringMeta is the metadata of a string.
 type StringMeta struct {
 	Name        locale.Localized
@@ -201,6 +247,10 @@ type StringMeta struct {
 	Placeholder locale.Localized
 	Validate    func(string) error
 	Multiline   bool
+	// ValidateLive, if true, makes the widget validate the string as the user
+	// types instead of only when they commit it. Nothing is published until
+	// the user commits a valid string.
+	ValidateLive bool
 }
 
 // Meta returns the PropMeta for StringMeta. It implements Prop.
@@ -273,8 +323,19 @@ func (s *String) UnmarshalJSON(blob []byte) error {
 	if err := json.Unmarshal(blob, &v); err != nil {
 		return err
 	}
-	s.Publish(v)
-	return nil
+	return s.Publish(v)
+}
+
+// AnyValue implements AnyProp.
+func (s *String) AnyValue() interface{} { return s.Value() }
+
+// AnyPublish implements AnyProp. The string is validated like in Publish.
+func (s *String) AnyPublish(v interface{}) error {
+	sv, ok := v.(string)
+	if !ok {
+		return ErrInvalidAnyType
+	}
+	return s.Publish(sv)
 }
 
 // CreateWidget creates either a *gtk.Entry or a *gtk.TextView.
@@ -285,9 +346,13 @@ func (s *String) CreateWidget(ctx context.Context, save func()) gtk.Widgetter {
 	entry.AddCSSClass("prefui-prop-string")
 	entry.SetWidthChars(10)
 	entry.SetPlaceholderText(s.Placeholder.String())
-	entry.ConnectChanged(func() {
-		setEntryIcon(entry, "object-select", "")
-	})
+	if s.ValidateLive && s.Validate != nil {
+		s.bindLiveValidation(entry)
+	} else {
+		entry.ConnectChanged(func() {
+			setEntryIcon(entry, "object-select", "")
+		})
+	}
 	bindPropWidget(s, entry, "activate,icon-press", propFuncs{
 		save: save,
 		set: func() {
@@ -306,6 +371,33 @@ func (s *String) CreateWidget(ctx context.Context, save func()) gtk.Widgetter {
 	return entry
 }
 
+// validateDelay is the delay in milliseconds after the user stops typing before
+// the string is validated live.
+const validateDelay = 350
+
+func (s *String) bindLiveValidation(entry *gtk.Entry) {
+	var pending glib.SourceHandle
+	cancel := func() {
+		if pending != 0 {
+			glib.SourceRemove(pending)
+			pending = 0
+		}
+	}
+
+	entry.ConnectChanged(func() {
+		cancel()
+		pending = glib.TimeoutAdd(validateDelay, func() {
+			pending = 0
+			if err := s.Validate(entry.Text()); err != nil {
+				setEntryIcon(entry, "dialog-error", "Error: "+err.Error())
+			} else {
+				setEntryIcon(entry, "object-select", "")
+			}
+		})
+	})
+	entry.ConnectUnmap(cancel)
+}
+
 // WidgetIsLarge returns true.
 func (s *String) WidgetIsLarge() bool { return true }
 
@@ -327,6 +419,45 @@ type EnumListMeta[T comparable] struct {
 	PropMeta
 	Validate func(T) error
 	Options  []T
+	// Display maps options to the labels shown to the user. Options that
+	// aren't in Display are shown using their String method or fmt.Sprint.
+	Display map[T]locale.Localized
+	// Disabled, if not nil, reports options that are currently unavailable,
+	// e.g. because a codec isn't installed. They are still valid values, but
+	// they're greyed out in the dropdown with the reason as their tooltip,
+	// and they cannot be selected.
+	Disabled func(T) (reason locale.Localized, disabled bool)
+}
+
+// EnumOption is an option of an EnumList along with its display label.
+type EnumOption[T comparable] struct {
+	Value   T
+	Display locale.Localized
+}
+
+// NewEnumListOptions creates a new EnumList whose Options and Display are
+// both derived from the given options, so that each option is declared once
+// together with its label. For example:
+//
+//	var Theme = prefs.NewEnumListOptions(ThemeSystem, prefs.PropMeta{
+//	    Name:    "Theme",
+//	    Section: "Appearance",
+//	},
+//	    prefs.EnumOption[Theme]{Value: ThemeSystem, Display: "Follow System"},
+//	    prefs.EnumOption[Theme]{Value: ThemeLight, Display: "Light"},
+//	    prefs.EnumOption[Theme]{Value: ThemeDark, Display: "Dark"},
+//	)
+func NewEnumListOptions[T comparable](def T, prop PropMeta, options ...EnumOption[T]) *EnumList[T] {
+	meta := EnumListMeta[T]{
+		PropMeta: prop,
+		Options:  make([]T, len(options)),
+		Display:  make(map[T]locale.Localized, len(options)),
+	}
+	for i, opt := range options {
+		meta.Options[i] = opt.Value
+		meta.Display[opt.Value] = opt.Display
+	}
+	return NewEnumList(def, meta)
 }
 
 // NewEnumList creates a new EnumList instance.
@@ -386,6 +517,25 @@ func (l *EnumList[T]) UnmarshalJSON(blob []byte) error {
 	return nil
 }
 
+// AnyValue implements AnyProp.
+func (l *EnumList[T]) AnyValue() interface{} { return l.Value() }
+
+// AnyPublish implements AnyProp. Unlike Publish, an error is returned instead
+// of panicking if the value isn't within Options.
+func (l *EnumList[T]) AnyPublish(v interface{}) error {
+	tv, ok := v.(T)
+	if !ok {
+		return ErrInvalidAnyType
+	}
+
+	if !l.IsValid(tv) {
+		return fmt.Errorf("enum %v is not a known value", tv)
+	}
+
+	l.Publish(tv)
+	return nil
+}
+
 // IsValid returns true if the given value is a valid enum value.
 func (l *EnumList[T]) IsValid(str T) bool {
 	for _, opt := range l.Options {
@@ -400,17 +550,21 @@ func (l *EnumList[T]) IsValid(str T) bool {
 func (l *EnumList[T]) CreateWidget(ctx context.Context, save func()) gtk.Widgetter {
 	items := make([]string, len(l.Options))
 	for i, opt := range l.Options {
-		switch opt := any(opt).(type) {
-		case string:
-			items[i] = opt
-		case fmt.Stringer:
-			items[i] = opt.String()
-		default:
-			items[i] = fmt.Sprint(opt)
+		items[i] = l.displayOption(opt)
+	}
+
+	var disabled gtkutil.DropDownDisabledFunc
+	if l.Disabled != nil {
+		disabled = func(i int) (string, bool) {
+			reason, ok := l.Disabled(l.Options[i])
+			if reason == "" {
+				return "", ok
+			}
+			return reason.String(), ok
 		}
 	}
 
-	dropdown := gtk.NewDropDownFromStrings(items)
+	dropdown := gtkutil.NewSearchableDropDownDisabled(items, disabled, nil)
 	dropdown.AddCSSClass("prefui-prop")
 	dropdown.AddCSSClass("prefui-prop-enumlist")
 
@@ -432,6 +586,22 @@ func (l *EnumList[T]) CreateWidget(ctx context.Context, save func()) gtk.Widgett
 // WidgetIsLarge returns false.
 func (l *EnumList[T]) WidgetIsLarge() bool { return false }
 
+// displayOption returns the label of the given option.
+func (l *EnumList[T]) displayOption(opt T) string {
+	if display, ok := l.Display[opt]; ok {
+		return display.String()
+	}
+
+	switch opt := any(opt).(type) {
+	case string:
+		return opt
+	case fmt.Stringer:
+		return opt.String()
+	default:
+		return fmt.Sprint(opt)
+	}
+}
+
 type propFuncs struct {
 	save    func()
 	set     func()
@@ -46,14 +46,39 @@ const (
 	Message = "message"
 )
 
+// Policy decides what happens when a sound is played while another sound is
+// still playing.
+type Policy int32
+
+const (
+	// Drop drops the new sound. This is the default policy.
+	Drop Policy = iota
+	// Queue plays the new sound after all sounds before it are done.
+	Queue
+	// Replace stops the playing sound and drops all queued sounds, then plays
+	// the new sound right away.
+	Replace
+)
+
+var policy atomic.Int32
+
+// SetPolicy sets the policy used when a sound is played while another sound
+// is still playing. Only one sound is ever played at a time.
+//
+// This function can be called from any thread.
+func SetPolicy(p Policy) {
+	policy.Store(int32(p))
+}
+
 var (
 	loadedSounds   = map[string]loadedSound{}
 	loadedSoundsMu sync.RWMutex
 )
 
 type loadedSound struct {
-	playing bool
-	file    *gtk.MediaFile
+	file *gtk.MediaFile
+	// playback is the playback that the file was last played for.
+	playback Playback
 }
 
 func getLoadedSound(id string) (loadedSound, bool) {
@@ -71,12 +96,12 @@ func setLoadedSound(id string, sound loadedSound) {
 	loadedSounds[id] = sound
 }
 
-func setLoadedSoundPlaying(id string, playing bool) {
+func setLoadedSoundPlayback(id string, pb Playback) {
 	loadedSoundsMu.Lock()
 	defer loadedSoundsMu.Unlock()
 
 	if sound, ok := loadedSounds[id]; ok {
-		sound.playing = playing
+		sound.playback = pb
 		loadedSounds[id] = sound
 	}
 }
@@ -88,124 +113,279 @@ func unloadSound(id string) {
 	delete(loadedSounds, id)
 }
 
+// soundDebounce is the minimum gap between the end of a sound and the start of
+// the next one. Sounds played within this gap are handled according to the
+// policy as if the previous sound is still playing. It is also the minimum
+// time between two plays of the same sound; plays of the same sound within it
+// are dropped regardless of the policy.
 const soundDebounce = 200 * time.Millisecond
 
+// maxSoundDuration is the longest that a sound can play for. A playback that
+// isn't done by then is stopped, so that a backend that never finishes can't
+// keep every other sound from playing.
+var maxSoundDuration = 10 * time.Second
+
+// player keeps track of the sound that is currently playing and the sounds
+// that are queued after it.
+var player struct {
+	sync.Mutex
+	// gen is incremented every time a sound starts or is stopped, so that a
+	// stopped sound cannot end the sound that replaced it.
+	gen  uint64
+	busy bool
+	// done is true once the current playback is done and the player is
+	// waiting for soundDebounce to pass.
+	done    bool
+	stop    func()
+	pending []queuedSound
+	// lastPlayed maps sound IDs to the last time Play accepted them.
+	lastPlayed map[string]time.Time
+}
+
+// Backend plays sounds for Play. The default backend plays sounds using
+// Canberra, falling back to GTK media files and then the display bell. Other
+// backends are mostly useful for testing without audio hardware.
+type Backend interface {
+	// Play plays the sound with the given ID. It is called in its own
+	// goroutine, and it must call pb.Done once the sound is done playing,
+	// including when it fails to play.
+	Play(app *app.Application, id string, pb Playback)
+}
+
+// BackendFunc is a function that implements Backend.
+type BackendFunc func(app *app.Application, id string, pb Playback)
+
+// Play implements Backend.
+func (f BackendFunc) Play(app *app.Application, id string, pb Playback) {
+	f(app, id, pb)
+}
+
+var backend atomic.Pointer[Backend]
+
+// SetBackend sets the backend used to play sounds. A nil backend restores the
+// default one.
+//
+// This function can be called from any thread.
+func SetBackend(b Backend) {
+	if b == nil {
+		backend.Store(nil)
+		return
+	}
+	backend.Store(&b)
+}
+
+type queuedSound struct {
+	app *app.Application
+	id  string
+}
+
+// Playback is a handle to a single play of a sound. It is given to the
+// Backend.
+type Playback struct{ gen uint64 }
+
+// Stopped returns true if the playback is stopped using Stop, replaced by
+// another sound, or stopped for taking too long to play.
+func (pb Playback) Stopped() bool {
+	player.Lock()
+	defer player.Unlock()
+
+	return player.gen != pb.gen
+}
+
+// SetStop sets the function that is called to stop the playback early, such
+// as when it's replaced by another sound. The playback is considered done once
+// it's stopped, so Done doesn't have to be called afterwards.
+func (pb Playback) SetStop(stop func()) {
+	player.Lock()
+	defer player.Unlock()
+
+	if player.gen == pb.gen {
+		player.stop = stop
+	}
+}
+
+// Done marks the playback as done. The next queued sound is played after
+// soundDebounce.
+func (pb Playback) Done() {
+	player.Lock()
+	defer player.Unlock()
+
+	if player.gen != pb.gen || !player.busy || player.done {
+		return
+	}
+
+	player.stop = nil
+	player.done = true
+	nextLocked(pb.gen)
+}
+
+// nextLocked plays the next queued sound once soundDebounce has passed, unless
+// the playback of the given generation is replaced or stopped by then.
+func nextLocked(gen uint64) {
+	time.AfterFunc(soundDebounce, func() {
+		player.Lock()
+		defer player.Unlock()
+
+		if player.gen != gen {
+			// Replaced or stopped during the gap.
+			return
+		}
+
+		if len(player.pending) > 0 {
+			next := player.pending[0]
+			player.pending = player.pending[1:]
+			startLocked(next.app, next.id)
+			return
+		}
+
+		player.busy = false
+	})
+}
+
+func startLocked(app *app.Application, id string) {
+	player.gen++
+	player.busy = true
+	player.done = false
+	player.stop = nil
+
+	gen := player.gen
+	time.AfterFunc(maxSoundDuration, func() {
+		player.Lock()
+		defer player.Unlock()
+
+		if player.gen != gen || player.done {
+			return
+		}
+
+		slog.Warn(
+			"sound is taking too long to play, stopping it",
+			"module", "sounds",
+			"id", id)
+
+		// Stop the playback, but stay busy until soundDebounce has passed
+		// like any other playback that's done.
+		stopLocked()
+		player.busy = true
+		player.done = true
+		nextLocked(player.gen)
+	})
+
+	var b Backend = BackendFunc(play)
+	if custom := backend.Load(); custom != nil {
+		b = *custom
+	}
+
+	go b.Play(app, id, Playback{player.gen})
+}
+
+func stopLocked() {
+	if player.stop != nil {
+		player.stop()
+	}
+
+	player.gen++
+	player.busy = false
+	player.done = false
+	player.stop = nil
+}
+
 // Play plays the given sound ID. It first uses Canberra, falling back to
 // ~/.cache/gotktrix/{id}.opus, then the embedded audio (if any), then
-// display.Beep() otherwise.
+// display.Beep() otherwise. If another sound is still playing, then the sound
+// is handled according to the policy set using SetPolicy.
 //
 // Play is asynchronous; it returning does not mean the audio has successfully
 // been played to the user.
 func Play(app *app.Application, id string) {
-	go play(app, id)
+	player.Lock()
+	defer player.Unlock()
+
+	now := time.Now()
+	if last, ok := player.lastPlayed[id]; ok && now.Sub(last) < soundDebounce {
+		slog.Debug(
+			"not playing sound, played too recently",
+			"module", "sounds",
+			"id", id)
+		return
+	}
+
+	if player.lastPlayed == nil {
+		player.lastPlayed = make(map[string]time.Time)
+	}
+	player.lastPlayed[id] = now
+
+	if player.busy {
+		switch Policy(policy.Load()) {
+		case Queue:
+			player.pending = append(player.pending, queuedSound{app, id})
+			return
+		case Replace:
+			player.pending = nil
+			stopLocked()
+		default:
+			slog.Debug(
+				"not playing sound, another sound is playing",
+				"module", "sounds",
+				"id", id)
+			return
+		}
+	}
+
+	startLocked(app, id)
+}
+
+// Stop stops the sound that is currently playing, if any, and drops all queued
+// sounds.
+//
+// This function can be called from any thread.
+func Stop() {
+	player.Lock()
+	defer player.Unlock()
+
+	player.pending = nil
+	stopLocked()
 }
 
-func play(app *app.Application, id string) {
+func play(app *app.Application, id string, pb Playback) {
 	sound, ok := getLoadedSound(id)
 	if !ok {
 		// If we can play with Canberra, we don't need to load the sound.
 		// Mark the sound as loaded to prevent future loading.
-		if playWithCanberra(id) {
+		if playWithCanberra(id, pb) {
 			setLoadedSound(id, loadedSound{})
+			pb.Done()
 			return
 		}
 
-		soundFilename := id
-		if filepath.Ext(soundFilename) == "" {
-			soundFilename += ".opus"
-		}
-
-		soundFilepath := app.CachePath("sounds", soundFilename)
-
-		if _, err := os.Stat(soundFilepath); err != nil {
-			if !os.IsNotExist(err) {
-				slog.Error(
-					"cannot stat sound file, playing fallback beep",
-					"module", "sounds",
-					"err", err,
-					"id", id,
-					"path", soundFilepath)
-				beep()
-				return
-			}
-
-			if err := copyToFS(soundFilepath, soundFilename); err != nil {
-				slog.Error(
-					"cannot copy sound file to disk, playing fallback beep",
-					"module", "sounds",
-					"err", err,
-					"id", id,
-					"path", soundFilepath)
-				beep()
-				return
-			}
+		soundFilepath, err := prepareSoundFile(app, id)
+		if err != nil {
+			slog.Error(
+				"cannot prepare sound file, playing fallback beep",
+				"module", "sounds",
+				"err", err,
+				"id", id,
+				"path", soundFilepath)
+			beep()
+			pb.Done()
+			return
 		}
 
 		glib.IdleAdd(func() {
 			var soundFile *gtk.MediaFile
 
-			if sound, ok := getLoadedSound(id); ok {
+			if sound, ok := getLoadedSound(id); ok && sound.file != nil {
 				soundFile = sound.file
-				setLoadedSoundPlaying(id, true)
+				setLoadedSoundPlayback(id, pb)
 			} else {
-				slog.Debug(
-					"creating new media file for sound",
-					"module", "sounds",
-					"id", id,
-					"path", soundFilepath)
-
-				soundFile = gtk.NewMediaFileForFilename(soundFilepath)
-				soundFile.NotifyProperty("error", func() {
-					slog.Error(
-						"could not load sound file, playing fallback beep",
-						"module", "sounds",
-						"err", soundFile.Error(),
-						"id", id,
-						"path", soundFilepath)
-					beep()
-					unloadSound(id)
-				})
-				soundFile.NotifyProperty("playing", func() {
-					if soundFile.Playing() {
-						slog.Debug(
-							"playing sound with loaded media file",
-							"module", "sounds",
-							"id", id,
-							"path", soundFilepath)
-					} else {
-						slog.Debug(
-							"sound file stopped playing",
-							"module", "sounds",
-							"id", id,
-							"path", soundFilepath)
-						setLoadedSoundPlaying(id, false)
-					}
-				})
-
-				setLoadedSound(id, loadedSound{
-					playing: true,
-					file:    soundFile,
-				})
-			}
-
-			if volume := volume.Load(); volume != nil {
-				soundFile.SetVolume(*volume)
+				soundFile = loadSoundFile(id, soundFilepath, pb)
 			}
 
-			soundFile.Play()
+			playMediaFile(soundFile, pb)
 		})
 
 		return
 	}
 
-	if sound.playing {
-		slog.Debug(
-			"not playing sound, already playing",
-			"module", "sounds",
-			"id", id)
-		return
-	}
-
 	slog.Debug(
 		"sound loaded from cache, playing",
 		"module", "sounds",
@@ -214,25 +394,177 @@ func play(app *app.Application, id string) {
 
 	if sound.file != nil {
 		glib.IdleAdd(func() {
-			setLoadedSoundPlaying(id, true)
-			sound.file.Play()
+			setLoadedSoundPlayback(id, pb)
+			playMediaFile(sound.file, pb)
 		})
 		return
 	}
 
-	if playWithCanberra(id) {
+	if playWithCanberra(id, pb) {
+		pb.Done()
 		return
 	}
 
 	// If Canberra fails after a successful play, we'll wipe the cache
 	// and play the sound again.
 	unloadSound(id)
-	play(app, id)
+	play(app, id, pb)
+}
+
+// prepareSoundFile makes sure that the sound file of the given ID is in the
+// cache by copying it from SoundsFS if needed. The path to the file is
+// returned.
+func prepareSoundFile(app *app.Application, id string) (string, error) {
+	soundFilename := id
+	if filepath.Ext(soundFilename) == "" {
+		soundFilename += ".opus"
+	}
+
+	soundFilepath := app.CachePath("sounds", soundFilename)
+
+	if _, err := os.Stat(soundFilepath); err != nil {
+		if !os.IsNotExist(err) {
+			return soundFilepath, fmt.Errorf("cannot stat sound file: %w", err)
+		}
+
+		if err := copyToFS(soundFilepath, soundFilename); err != nil {
+			return soundFilepath, fmt.Errorf("cannot copy sound file to disk: %w", err)
+		}
+	}
+
+	return soundFilepath, nil
+}
+
+// loadSoundFile creates a new media file for the sound at the given path and
+// stores it as the loaded sound of the given ID. It must be called on the main
+// thread.
+func loadSoundFile(id, soundFilepath string, pb Playback) *gtk.MediaFile {
+	slog.Debug(
+		"creating new media file for sound",
+		"module", "sounds",
+		"id", id,
+		"path", soundFilepath)
+
+	soundFile := gtk.NewMediaFileForFilename(soundFilepath)
+	soundFile.NotifyProperty("error", func() {
+		slog.Error(
+			"could not load sound file, playing fallback beep",
+			"module", "sounds",
+			"err", soundFile.Error(),
+			"id", id,
+			"path", soundFilepath)
+		beep()
+
+		sound, _ := getLoadedSound(id)
+		unloadSound(id)
+		sound.playback.Done()
+	})
+	soundFile.NotifyProperty("ended", func() {
+		if soundFile.Ended() {
+			sound, _ := getLoadedSound(id)
+			sound.playback.Done()
+		}
+	})
+	soundFile.NotifyProperty("playing", func() {
+		if soundFile.Playing() {
+			slog.Debug(
+				"playing sound with loaded media file",
+				"module", "sounds",
+				"id", id,
+				"path", soundFilepath)
+		} else {
+			slog.Debug(
+				"sound file stopped playing",
+				"module", "sounds",
+				"id", id,
+				"path", soundFilepath)
+
+			sound, _ := getLoadedSound(id)
+			sound.playback.Done()
+		}
+	})
+
+	setLoadedSound(id, loadedSound{
+		file:     soundFile,
+		playback: pb,
+	})
+
+	return soundFile
+}
+
+// Preload loads the given sounds ahead of time, so that playing them for the
+// first time doesn't have to copy them into the cache and load them first.
+// Sounds that are already loaded are skipped. Nothing is loaded if Canberra is
+// available, since it plays sounds without loading them.
+//
+// Preload is asynchronous, and it can be called during startup.
+func Preload(app *app.Application, ids ...string) {
+	go func() {
+		if canberraAvailable() {
+			return
+		}
+
+		for _, id := range ids {
+			id := id
+
+			if _, ok := getLoadedSound(id); ok {
+				continue
+			}
+
+			soundFilepath, err := prepareSoundFile(app, id)
+			if err != nil {
+				slog.Warn(
+					"cannot preload sound file",
+					"module", "sounds",
+					"err", err,
+					"id", id,
+					"path", soundFilepath)
+				continue
+			}
+
+			glib.IdleAdd(func() {
+				if _, ok := getLoadedSound(id); !ok {
+					loadSoundFile(id, soundFilepath, Playback{})
+				}
+			})
+		}
+	}()
+}
+
+// playMediaFile plays the given media file from the start. It must be called
+// on the main thread.
+func playMediaFile(file *gtk.MediaFile, pb Playback) {
+	if pb.Stopped() {
+		// Stopped before the main loop got to it.
+		return
+	}
+
+	if volume := volume.Load(); volume != nil {
+		file.SetVolume(*volume)
+	}
+
+	pb.SetStop(func() {
+		glib.IdleAdd(func() {
+			file.Pause()
+			file.Seek(0)
+		})
+	})
+
+	file.Play()
 }
 
 var enableCanberra = true
 
-func playWithCanberra(id string) bool {
+// canberraAvailable returns true if sounds may be played using Canberra.
+func canberraAvailable() bool {
+	if !enableCanberra {
+		return false
+	}
+	_, err := exec.LookPath("canberra-gtk-play")
+	return err == nil
+}
+
+func playWithCanberra(id string, pb Playback) bool {
 	if !enableCanberra {
 		return false
 	}
@@ -245,7 +577,18 @@ func playWithCanberra(id string) bool {
 	cmd := exec.Command("canberra-gtk-play", "--id", id)
 	cmd.Stderr = os.Stderr
 
-	if err := cmd.Run(); err != nil {
+	err := cmd.Start()
+	if err == nil {
+		pb.SetStop(func() { cmd.Process.Kill() })
+		err = cmd.Wait()
+	}
+
+	if err != nil {
+		if pb.Stopped() {
+			// Killed by Stop or by a replacing sound.
+			return true
+		}
+
 		slog.Error(
 			"failed to play sound with canberra",
 			"module", "sounds",
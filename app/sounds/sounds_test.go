@@ -3,7 +3,11 @@ package sounds
 import (
 	"context"
 	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotkit/app"
@@ -60,6 +64,149 @@ func TestPlay(t *testing.T) {
 	// Output:
 }
 
+func TestPlayDebounce(t *testing.T) {
+	injectLogger(t)
+	resetPlayer(t)
+
+	var played atomic.Int32
+	SetBackend(BackendFunc(func(_ *app.Application, id string, pb Playback) {
+		played.Add(1)
+		pb.Done()
+	}))
+	t.Cleanup(func() { SetBackend(nil) })
+
+	// Queue every sound, so that only the debouncing can drop them.
+	SetPolicy(Queue)
+	t.Cleanup(func() { SetPolicy(Drop) })
+
+	for i := 0; i < 10; i++ {
+		Play(nil, Message)
+	}
+	time.Sleep(3 * soundDebounce)
+
+	if n := played.Load(); n != 1 {
+		t.Fatalf("sound played %d times, expected 1", n)
+	}
+
+	// The sound can be played again once the debounce window has passed.
+	Play(nil, Message)
+	time.Sleep(3 * soundDebounce)
+
+	if n := played.Load(); n != 2 {
+		t.Fatalf("sound played %d times, expected 2", n)
+	}
+}
+
+func TestPlayPolicy(t *testing.T) {
+	// finished lists the sounds that played until the end without being
+	// stopped, in the order that they finished.
+	tests := []struct {
+		name     string
+		policy   Policy
+		finished []string
+	}{
+		{"Drop", Drop, []string{"a"}},
+		{"Queue", Queue, []string{"a", "b", "c"}},
+		{"Replace", Replace, []string{"c"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			injectLogger(t)
+			resetPlayer(t)
+
+			var mu sync.Mutex
+			var finished []string
+
+			SetBackend(BackendFunc(func(_ *app.Application, id string, pb Playback) {
+				time.Sleep(soundDebounce / 2)
+				if pb.Stopped() {
+					return
+				}
+
+				mu.Lock()
+				finished = append(finished, id)
+				mu.Unlock()
+
+				pb.Done()
+			}))
+			t.Cleanup(func() { SetBackend(nil) })
+
+			SetPolicy(test.policy)
+			t.Cleanup(func() { SetPolicy(Drop) })
+
+			Play(nil, "a")
+			Play(nil, "b")
+			Play(nil, "c")
+			time.Sleep(10 * soundDebounce)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if !slices.Equal(finished, test.finished) {
+				t.Errorf("finished playing %q, expected %q", finished, test.finished)
+			}
+		})
+	}
+}
+
+func TestPlayStuck(t *testing.T) {
+	injectLogger(t)
+	resetPlayer(t)
+
+	oldMax := maxSoundDuration
+	maxSoundDuration = 2 * soundDebounce
+	t.Cleanup(func() { maxSoundDuration = oldMax })
+
+	var mu sync.Mutex
+	var played []string
+	var stuck Playback
+
+	// The backend never calls Done, so every sound is stopped once it has
+	// played for too long.
+	SetBackend(BackendFunc(func(_ *app.Application, id string, pb Playback) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if len(played) == 0 {
+			stuck = pb
+		}
+		played = append(played, id)
+	}))
+	t.Cleanup(func() { SetBackend(nil) })
+
+	SetPolicy(Queue)
+	t.Cleanup(func() { SetPolicy(Drop) })
+
+	Play(nil, "a")
+	Play(nil, "b")
+	time.Sleep(4 * maxSoundDuration)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !slices.Equal(played, []string{"a", "b"}) {
+		t.Errorf("played %q, expected %q", played, []string{"a", "b"})
+	}
+	if !stuck.Stopped() {
+		t.Error("stuck playback was not stopped")
+	}
+}
+
+// resetPlayer stops the sounds left playing by other tests and forgets which
+// sounds they played, so that the debouncing doesn't depend on the order that
+// the tests run in. The player is stopped again once the test is done.
+func resetPlayer(t *testing.T) {
+	t.Helper()
+
+	Stop()
+	player.Lock()
+	player.lastPlayed = nil
+	player.Unlock()
+
+	t.Cleanup(Stop)
+}
+
 func injectLogger(t *testing.T) {
 	t.Helper()
 
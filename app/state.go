@@ -1,13 +1,16 @@
 package app
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"sync"
 
 	"github.com/diamondburned/gotk4/pkg/core/glib"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
 	"github.com/diamondburned/gotkit/utils/config"
 )
 
@@ -21,7 +24,10 @@ type State struct {
 
 	mut    sync.Mutex
 	state  map[string]json.RawMessage
+	data   []byte // last data read from or written to disk
 	loaded bool
+
+	watch *stateWatch // main thread only
 }
 
 // AcquireState creates a new Config instance.
@@ -56,7 +62,7 @@ func acquireState(path string) *State {
 	}
 
 	s = &State{path: path}
-	s.store = config.NewConfigStore(s.snapshotFunc)
+	s.store = config.NewConfigStoreWithError(s.snapshotFunc)
 
 	registry.cfgs[path] = s
 	return s
@@ -97,6 +103,27 @@ func (s *State) Get(key string, dst interface{}) bool {
 	return true
 }
 
+// GetWithError is like Get, except it returns the error if the key exists but
+// its value cannot be unmarshaled into dst, so callers can tell an absent key
+// apart from a value of the wrong shape. ok is false if the key doesn't exist.
+// The error is not logged.
+func (s *State) GetWithError(key string, dst interface{}) (ok bool, err error) {
+	s.mut.Lock()
+	s.load()
+	b, ok := s.state[key]
+	s.mut.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+
+	if err := json.Unmarshal(b, dst); err != nil {
+		return true, fmt.Errorf("cannot unmarshal state key %q into %T: %w", key, dst, err)
+	}
+
+	return true, nil
+}
+
 // GetAsync gets the value of the key asynchronously.
 // The given callback may be immediately called if the value is already
 // available, or it may be called later when the value is available.
@@ -201,6 +228,58 @@ func (s *State) Set(key string, val interface{}) {
 	s.store.Save()
 }
 
+// SetMany sets the values of all the given keys at once and saves the state
+// only once. Like Set, a nil value deletes its key.
+func (s *State) SetMany(values map[string]interface{}) {
+	marshaled := make(map[string][]byte, len(values))
+	for key, val := range values {
+		if val == nil {
+			marshaled[key] = nil
+			continue
+		}
+
+		b, err := json.Marshal(val)
+		if err != nil {
+			log.Panicf("cannot marshal %T for key %q: %v", val, key, err)
+		}
+		marshaled[key] = b
+	}
+
+	s.mut.Lock()
+	s.load()
+	for key, b := range marshaled {
+		if b == nil {
+			delete(s.state, key)
+		} else {
+			s.state[key] = b
+		}
+	}
+	s.mut.Unlock()
+
+	s.store.Save()
+}
+
+// Flush synchronously writes any pending change to disk. It must be called on
+// the main thread. All states are automatically flushed when the application
+// shuts down.
+func (s *State) Flush() {
+	s.store.Flush()
+}
+
+// flushStates flushes all acquired states.
+func flushStates() {
+	registry.RLock()
+	states := make([]*State, 0, len(registry.cfgs))
+	for _, s := range registry.cfgs {
+		states = append(states, s)
+	}
+	registry.RUnlock()
+
+	for _, s := range states {
+		s.Flush()
+	}
+}
+
 // Delete calls Set(key, nil).
 func (s *State) Delete(key string) {
 	s.Set(key, nil)
@@ -213,22 +292,23 @@ func (s *State) load() {
 	s.loaded = true
 	s.state = make(map[string]json.RawMessage)
 
-	f, err := os.Open(s.path)
+	b, err := config.ReadFile(s.path)
 	if err != nil {
 		if !os.IsNotExist(err) {
 			log.Println("cannot open preference:", err)
 		}
 		return
 	}
-	defer f.Close()
 
-	if err := json.NewDecoder(f).Decode(&s.state); err != nil {
+	if err := json.Unmarshal(b, &s.state); err != nil {
 		log.Printf("preference %q has invalid JSON: %v", s.path, err)
 		return
 	}
+
+	s.data = b
 }
 
-func (s *State) snapshotFunc() func() {
+func (s *State) snapshotFunc() func() error {
 	s.mut.Lock()
 	defer s.mut.Unlock()
 
@@ -241,9 +321,122 @@ func (s *State) snapshotFunc() func() {
 		log.Panicln("cannot marshal kvstate.State:", err)
 	}
 
-	return func() {
+	return func() error {
 		if err := config.WriteFile(s.path, b); err != nil {
-			log.Println("cannot save kvstate:", err)
+			return fmt.Errorf("cannot save kvstate: %w", err)
+		}
+
+		// Remember what we wrote, so that watchers don't reload our own
+		// changes.
+		s.mut.Lock()
+		s.data = b
+		s.mut.Unlock()
+
+		return nil
+	}
+}
+
+// stateWatch monitors the file of a State for changes made by other processes.
+type stateWatch struct {
+	monitor *gio.FileMonitor
+	subs    map[int]func()
+	next    int
+	pending bool
+}
+
+// Watch calls f whenever the state file is changed by another process, such as
+// another instance of the application, after the new state has been loaded.
+// Changes made through s itself don't call f. Any change that isn't saved yet is
+// lost when the state is reloaded. Watch must be called on the main thread, and
+// the returned function must also be called on the main thread to stop
+// watching.
+func (s *State) Watch(f func()) (unwatch func()) {
+	if s.watch == nil {
+		monitor, err := gio.NewFileForPath(s.path).MonitorFile(context.Background(), gio.FileMonitorWatchMoves)
+		if err != nil {
+			log.Printf("cannot watch state %q: %v", s.path, err)
+			return func() {}
+		}
+
+		s.watch = &stateWatch{
+			monitor: gio.BaseFileMonitor(monitor),
+			subs:    make(map[int]func()),
+		}
+		s.watch.monitor.ConnectChanged(func(_, _ gio.Filer, event gio.FileMonitorEvent) {
+			switch event {
+			case
+				gio.FileMonitorEventChangesDoneHint,
+				gio.FileMonitorEventCreated,
+				gio.FileMonitorEventDeleted,
+				gio.FileMonitorEventMovedIn,
+				gio.FileMonitorEventRenamed:
+				s.scheduleReload()
+			}
+		})
+	}
+
+	id := s.watch.next
+	s.watch.next++
+	s.watch.subs[id] = f
+
+	return func() {
+		if s.watch == nil {
+			return
+		}
+		delete(s.watch.subs, id)
+		if len(s.watch.subs) == 0 {
+			s.watch.monitor.Cancel()
+			s.watch = nil
 		}
 	}
 }
+
+// scheduleReload reloads the state shortly, so that a burst of file events
+// only causes one reload.
+func (s *State) scheduleReload() {
+	if s.watch.pending {
+		return
+	}
+	s.watch.pending = true
+
+	watch := s.watch
+	glib.TimeoutAdd(100, func() {
+		watch.pending = false
+		if s.watch == watch && s.reload() {
+			for _, f := range watch.subs {
+				f()
+			}
+		}
+	})
+}
+
+// reload reads the state file again. It returns true if the file was changed
+// since it was last read or written.
+func (s *State) reload() bool {
+	b, err := config.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		log.Println("cannot reload preference:", err)
+		return false
+	}
+
+	state := make(map[string]json.RawMessage)
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &state); err != nil {
+			// The file might be in the middle of being written.
+			log.Printf("preference %q has invalid JSON: %v", s.path, err)
+			return false
+		}
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.loaded && bytes.Equal(b, s.data) {
+		return false
+	}
+
+	s.state = state
+	s.data = b
+	s.loaded = true
+	return true
+}
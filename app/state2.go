@@ -54,6 +54,15 @@ func (s *TypedState[StateT]) Get(key string, f func(StateT)) {
 	state.GetAsync(key, &value, func() { f(value) })
 }
 
+// GetWithError synchronously gets the value of the key. ok is false if the key
+// does not exist. err is non-nil if the key exists but its value doesn't match
+// StateT, which Get would silently ignore.
+func (s *TypedState[StateT]) GetWithError(key string) (value StateT, ok bool, err error) {
+	state := (*State)(s)
+	ok, err = state.GetWithError(key, &value)
+	return
+}
+
 // Exists returns true if key exists.
 func (s *TypedState[StateT]) Exists(key string, f func(bool)) {
 	state := (*State)(s)
@@ -66,12 +75,36 @@ func (s *TypedState[StateT]) Set(key string, value StateT) {
 	state.Set(key, value)
 }
 
+// SetMany sets the values of all the given keys and saves the state once.
+func (s *TypedState[StateT]) SetMany(values map[string]StateT) {
+	untyped := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		untyped[key] = value
+	}
+
+	state := (*State)(s)
+	state.SetMany(untyped)
+}
+
 // Delete deletes the key.
 func (s *TypedState[StateT]) Delete(key string) {
 	state := (*State)(s)
 	state.Delete(key)
 }
 
+// Flush synchronously writes any pending change to disk.
+func (s *TypedState[StateT]) Flush() {
+	state := (*State)(s)
+	state.Flush()
+}
+
+// Watch calls f whenever the state is changed by another process. See
+// State.Watch.
+func (s *TypedState[StateT]) Watch(f func()) (unwatch func()) {
+	state := (*State)(s)
+	return state.Watch(f)
+}
+
 // SingleStateKey defines a constant key for a state that only has one value.
 type SingleStateKey[StateT any] struct {
 	tails []string
@@ -99,6 +132,15 @@ func (s *TypedSingleState[StateT]) Get(f func(StateT)) {
 	state.GetAsync("", &value, func() { f(value) })
 }
 
+// GetWithError synchronously gets the value. ok is false if there is no value.
+// err is non-nil if the value doesn't match StateT, which Get would silently
+// ignore.
+func (s *TypedSingleState[StateT]) GetWithError() (value StateT, ok bool, err error) {
+	state := (*State)(s)
+	ok, err = state.GetWithError("", &value)
+	return
+}
+
 // Exists returns true if key exists.
 func (s *TypedSingleState[StateT]) Exists(f func(bool)) {
 	state := (*State)(s)
@@ -116,3 +158,16 @@ func (s *TypedSingleState[StateT]) Delete() {
 	state := (*State)(s)
 	state.Delete("")
 }
+
+// Flush synchronously writes any pending change to disk.
+func (s *TypedSingleState[StateT]) Flush() {
+	state := (*State)(s)
+	state.Flush()
+}
+
+// Watch calls f whenever the state is changed by another process. See
+// State.Watch.
+func (s *TypedSingleState[StateT]) Watch(f func()) (unwatch func()) {
+	state := (*State)(s)
+	return state.Watch(f)
+}
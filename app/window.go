@@ -30,7 +30,7 @@ func WrapWindow(app *Application, window *gtk.ApplicationWindow) *Window {
 	gtkutil.ScaleFactor()
 
 	window.SetApplication(app.Application)
-	if isDevel {
+	if app.IsDevel() {
 		window.AddCSSClass("devel")
 	}
 
@@ -83,6 +83,16 @@ func OpenURI(ctx context.Context, uri string) {
 	gtk.ShowURI(GTKWindowFromContext(ctx), uri, ts)
 }
 
+// ShowShortcutsWindow shows a Keyboard Shortcuts window listing all shortcuts
+// that are bound and described using gtkutil.DescribeShortcuts. The window is
+// transient for the context's window.
+func ShowShortcutsWindow(ctx context.Context) {
+	w := gtkutil.NewShortcutsWindow()
+	w.SetTransientFor(GTKWindowFromContext(ctx))
+	w.SetDestroyWithParent(true)
+	w.Present()
+}
+
 // Application returns the Window's parent Application instance.
 func (w *Window) Application() *Application { return w.app }
 
@@ -111,6 +121,45 @@ func (w *Window) SetLoading() {
 	w.NotifyChild(true, func() { spinner.Stop() })
 }
 
+// BindAsyncButton makes the button run work in a goroutine when it's clicked.
+// While work is running, the button is disabled and its content is replaced
+// with a spinner, so it's the inline counterpart to SetLoading. The content is
+// restored once work returns, and its error, if any, is shown using Error. The
+// context given to work is ctx.
+//
+// This function lives in package app rather than gtkutil, since it needs
+// Error.
+func BindAsyncButton(ctx context.Context, btn *gtk.Button, work func(context.Context) error) {
+	btn.ConnectClicked(func() {
+		child := btn.Child()
+
+		// Keep the button's width so it doesn't shrink around the spinner.
+		oldW, oldH := btn.SizeRequest()
+		btn.SetSizeRequest(max(oldW, btn.Width()), oldH)
+
+		spinner := gtk.NewSpinner()
+		spinner.Start()
+
+		btn.SetChild(spinner)
+		btn.SetSensitive(false)
+
+		go func() {
+			err := work(ctx)
+
+			glib.IdleAdd(func() {
+				spinner.Stop()
+				btn.SetChild(child)
+				btn.SetSizeRequest(oldW, oldH)
+				btn.SetSensitive(true)
+
+				if err != nil && ctx.Err() == nil {
+					Error(ctx, err)
+				}
+			})
+		}()
+	})
+}
+
 // NotifyChild calls f if the main window's child is changed. If once is true,
 // then f is never called again.
 func (w *Window) NotifyChild(once bool, f func()) {
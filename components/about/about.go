@@ -0,0 +1,110 @@
+// Package about provides an About window that is automatically populated from
+// the Application.
+package about
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/components/logui"
+	"github.com/diamondburned/gotkit/gtkutil"
+)
+
+// viewLogsURI is the link URI that opens the log viewer instead of being
+// opened by the system.
+const viewLogsURI = "gotkit-about:logs"
+
+// Window is an About window for the application inside the context. It wraps
+// adw.AboutWindow and adds a link to view the logs as well as an action to
+// copy the system information. The about.copy-system-info and about.view-logs
+// actions are bound to Ctrl+Shift+C and Ctrl+L respectively.
+type Window struct {
+	*adw.AboutWindow
+}
+
+// Show calls NewWindow then Show.
+func Show(ctx context.Context) {
+	w := NewWindow(ctx)
+	w.Show()
+}
+
+// NewWindow creates a new About window. The application name, ID and version
+// are taken from the Application inside the context. Callers may further
+// populate the window, e.g. by calling SetWebsite or SetLicenseType.
+func NewWindow(ctx context.Context) *Window {
+	win := app.GTKWindowFromContext(ctx)
+	app := app.FromContext(ctx)
+
+	w := Window{
+		AboutWindow: adw.NewAboutWindow(),
+	}
+	w.SetTransientFor(win)
+	w.SetModal(true)
+	w.SetDestroyWithParent(true)
+	w.SetApplicationName(app.Name())
+	w.SetApplicationIcon(app.ID())
+	w.SetVersion(app.Version())
+	w.SetDebugInfo(SystemInfo(ctx))
+	w.SetDebugInfoFilename(app.BaseID() + "-info.txt")
+	w.AddLink(locale.Get("View Logs"), viewLogsURI)
+
+	w.ConnectActivateLink(func(uri string) bool {
+		if uri == viewLogsURI {
+			logui.ShowDefaultViewer(ctx)
+			return true
+		}
+		return false
+	})
+
+	gtkutil.BindActionMap(w, map[string]func(){
+		"about.copy-system-info": func() { w.CopySystemInfo() },
+		"about.view-logs":        func() { logui.ShowDefaultViewer(ctx) },
+	})
+	gtkutil.AddActionShortcuts(w, map[string]string{
+		"<Control><Shift>c": "about.copy-system-info",
+		"<Control>l":        "about.view-logs",
+	})
+
+	return &w
+}
+
+// CopySystemInfo copies the system information into the clipboard.
+func (w *Window) CopySystemInfo() {
+	display := gdk.DisplayGetDefault()
+
+	clipboard := display.Clipboard()
+	clipboard.SetText(w.DebugInfo())
+}
+
+// SystemInfo returns a human-readable description of the application and the
+// system that it is running on. It is meant to be attached to bug reports.
+func SystemInfo(ctx context.Context) string {
+	app := app.FromContext(ctx)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Application: %s (%s)\n", app.Name(), app.ID())
+	fmt.Fprintf(&b, "Version: %s\n", app.Version())
+	fmt.Fprintf(&b, "Go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "GTK: %d.%d.%d\n",
+		gtk.GetMajorVersion(), gtk.GetMinorVersion(), gtk.GetMicroVersion())
+	fmt.Fprintf(&b, "libadwaita: %d.%d.%d\n",
+		adw.GetMajorVersion(), adw.GetMinorVersion(), adw.GetMicroVersion())
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			if strings.HasPrefix(setting.Key, "vcs") {
+				fmt.Fprintf(&b, "%s: %s\n", setting.Key, setting.Value)
+			}
+		}
+	}
+
+	return b.String()
+}
@@ -16,7 +16,9 @@ type Button struct {
 }
 
 // NewButton creates a new button. The icon's position is determined by the
-// given position type.
+// given position type. PosLeft and PosRight are relative to the reading
+// direction: in RTL locales, they're mirrored like the rest of the layout, so
+// PosLeft always puts the icon before the label.
 func NewButton(label locale.Localized, icon string, pos gtk.PositionType) *Button {
 	img := gtk.NewImageFromIconName(icon)
 	img.SetIconSize(gtk.IconSizeNormal)
@@ -0,0 +1,241 @@
+// Package commandpalette provides a searchable list of all the application's
+// commands that can be invoked by name.
+package commandpalette
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+)
+
+// Palette is a window that lists all shortcuts that are bound to an action and
+// described using gtkutil.AddDescribedActionShortcuts,
+// gtkutil.DescribeActionShortcuts or app.Application.AddDescribedActionShortcuts.
+// Only window (win.*) actions of the parent window and application (app.*)
+// actions are listed. Activating a command closes the palette and activates its
+// action on the focused widget of the parent window.
+type Palette struct {
+	*gtk.Window
+	Entry *gtk.SearchEntry
+	List  *gtk.ListBox
+
+	parent   *gtk.Window
+	app      *app.Application
+	commands []gtkutil.ShortcutInfo
+	// terms holds the lowercased search term of each command.
+	terms     []string
+	searching string
+}
+
+var _ = cssutil.WriteCSS(`
+	.commandpalette-entry {
+		margin: 8px;
+	}
+	.commandpalette-list row {
+		padding: 6px 8px;
+	}
+	.commandpalette-section {
+		font-size: 0.9em;
+	}
+`)
+
+// Show calls NewPalette then Show.
+func Show(ctx context.Context) {
+	p := NewPalette(ctx)
+	p.Show()
+}
+
+// NewPalette creates a new command palette for the window inside the context.
+func NewPalette(ctx context.Context) *Palette {
+	p := Palette{
+		parent: app.GTKWindowFromContext(ctx),
+		app:    app.FromContext(ctx),
+	}
+
+	for _, info := range gtkutil.ListShortcuts() {
+		if !p.canActivate(info.Action) {
+			continue
+		}
+		p.commands = append(p.commands, info)
+		p.terms = append(p.terms, strings.ToLower(info.Title.String()+" "+info.Section.String()))
+	}
+
+	p.List = gtk.NewListBox()
+	p.List.AddCSSClass("commandpalette-list")
+	p.List.SetSelectionMode(gtk.SelectionBrowse)
+	p.List.SetPlaceholder(newPlaceholder())
+	p.List.SetFilterFunc(func(row *gtk.ListBoxRow) bool {
+		return strings.Contains(p.terms[row.Index()], p.searching)
+	})
+	p.List.ConnectRowActivated(func(row *gtk.ListBoxRow) {
+		p.activate(row.Index())
+	})
+
+	for _, command := range p.commands {
+		p.List.Append(newCommandRow(command))
+	}
+
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	scroll.SetPropagateNaturalHeight(true)
+	scroll.SetMaxContentHeight(400)
+	scroll.SetVExpand(true)
+	scroll.SetChild(p.List)
+
+	p.Entry = gtk.NewSearchEntry()
+	p.Entry.AddCSSClass("commandpalette-entry")
+	p.Entry.SetPlaceholderText(locale.Get("Search Commands..."))
+	p.Entry.ConnectSearchChanged(func() { p.Search(p.Entry.Text()) })
+	p.Entry.ConnectActivate(func() {
+		if row := p.List.SelectedRow(); row != nil {
+			p.activate(row.Index())
+		}
+	})
+	p.Entry.ConnectStopSearch(func() { p.Close() })
+
+	box := gtk.NewBox(gtk.OrientationVertical, 0)
+	box.Append(p.Entry)
+	box.Append(scroll)
+
+	p.Window = gtk.NewWindow()
+	p.Window.AddCSSClass("commandpalette")
+	p.Window.SetTitle(locale.Get("Commands"))
+	p.Window.SetTransientFor(p.parent)
+	p.Window.SetModal(true)
+	p.Window.SetDestroyWithParent(true)
+	p.Window.SetDecorated(false)
+	p.Window.SetDefaultSize(500, -1)
+	p.Window.SetChild(box)
+
+	p.Entry.SetKeyCaptureWidget(p.Window)
+	gtkutil.BindClose(p.Window)
+	gtkutil.AddCallbackShortcuts(p.Window, map[string]func(){
+		"Down": func() { p.moveSelection(+1) },
+		"Up":   func() { p.moveSelection(-1) },
+	})
+
+	p.selectFirst()
+	return &p
+}
+
+func newCommandRow(command gtkutil.ShortcutInfo) *gtk.ListBoxRow {
+	title := gtk.NewLabel(command.Title.String())
+	title.SetXAlign(0)
+	title.SetHExpand(true)
+	title.SetEllipsize(pango.EllipsizeEnd)
+
+	section := gtk.NewLabel(command.Section.String())
+	section.AddCSSClass("commandpalette-section")
+	section.AddCSSClass("dim-label")
+
+	box := gtk.NewBox(gtk.OrientationHorizontal, 12)
+	box.Append(title)
+	box.Append(section)
+	if len(command.Accels) > 0 {
+		box.Append(gtk.NewShortcutLabel(command.Accels[0]))
+	}
+
+	row := gtk.NewListBoxRow()
+	row.SetChild(box)
+	return row
+}
+
+func newPlaceholder() gtk.Widgetter {
+	label := gtk.NewLabel(locale.Get("No commands found."))
+	label.AddCSSClass("dim-label")
+	label.SetMarginTop(12)
+	label.SetMarginBottom(12)
+	return label
+}
+
+// Search filters the list to only show commands matching the given query. The
+// first matching command is selected.
+func (p *Palette) Search(query string) {
+	p.searching = strings.ToLower(query)
+	p.List.InvalidateFilter()
+	p.selectFirst()
+}
+
+// selectFirst selects the first command that matches the current search.
+func (p *Palette) selectFirst() {
+	for i, term := range p.terms {
+		if strings.Contains(term, p.searching) {
+			p.List.SelectRow(p.List.RowAtIndex(i))
+			return
+		}
+	}
+	p.List.UnselectAll()
+}
+
+// moveSelection selects the next or previous matching command, depending on
+// the sign of delta.
+func (p *Palette) moveSelection(delta int) {
+	i := -1
+	if row := p.List.SelectedRow(); row != nil {
+		i = row.Index()
+	}
+
+	for i += delta; i >= 0 && i < len(p.terms); i += delta {
+		if strings.Contains(p.terms[i], p.searching) {
+			p.List.SelectRow(p.List.RowAtIndex(i))
+			return
+		}
+	}
+}
+
+// canActivate returns true if the given action exists in the parent window or
+// the application. The shortcut registry is shared by all windows, so actions
+// described by other windows must not be listed, or an unrelated action of the
+// same name would be activated instead.
+func (p *Palette) canActivate(action string) bool {
+	switch {
+	case strings.HasPrefix(action, "win."):
+		if p.parent == nil {
+			return false
+		}
+		actions, ok := coreglib.InternObject(p.parent).Cast().(gio.ActionMapper)
+		return ok && actions.LookupAction(strings.TrimPrefix(action, "win.")) != nil
+	case strings.HasPrefix(action, "app."):
+		return p.app != nil && p.app.LookupAction(strings.TrimPrefix(action, "app.")) != nil
+	default:
+		return false
+	}
+}
+
+// activate closes the palette and activates the command at index i.
+func (p *Palette) activate(i int) {
+	action := p.commands[i].Action
+	p.Close()
+
+	if p.parent == nil {
+		slog.Warn(
+			"command palette has no window to activate action on",
+			"module", "commandpalette",
+			"action", action)
+		return
+	}
+
+	// Activate the action from the focused widget, so actions bound to it or
+	// any of its parents can be found.
+	target := gtk.BaseWidget(p.parent)
+	if focus := p.parent.Focus(); focus != nil {
+		target = gtk.BaseWidget(focus)
+	}
+
+	if !target.ActivateAction(action, nil) {
+		slog.Warn(
+			"command palette cannot find action",
+			"module", "commandpalette",
+			"action", action)
+	}
+}
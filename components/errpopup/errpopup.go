@@ -2,9 +2,9 @@ package errpopup
 
 import (
 	"fmt"
-	"html"
 	"strings"
 
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
@@ -39,17 +39,22 @@ type dialogState struct {
 
 var windows = make(map[*gtk.Window]*dialogState)
 
+// indentError formats msg as Pango markup, putting each wrapped error on its
+// own indented line. Each part is escaped, so the error can't inject markup.
+//
+// textutil.EscapeMarkup can't be used here, since textutil indirectly imports
+// this package; glib.MarkupEscapeText is what it mirrors.
 func indentError(msg string) string {
 	parts := strings.Split(msg, ": ")
 
 	var builder strings.Builder
-	builder.WriteString(parts[0])
+	builder.WriteString(glib.MarkupEscapeText(parts[0]))
 
 	for i, part := range parts[1:] {
 		builder.WriteByte('\n')
 		builder.WriteString(strings.Repeat(" ", (i+1)*3))
 		builder.WriteString("- ")
-		builder.WriteString(html.EscapeString(part))
+		builder.WriteString(glib.MarkupEscapeText(part))
 	}
 
 	return builder.String()
@@ -0,0 +1,390 @@
+// Package imageviewer provides a window that shows a full-resolution image with
+// zooming and panning.
+package imageviewer
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gdkpixbuf/v2"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+	"github.com/diamondburned/gotkit/utils/osutil"
+)
+
+// Zoom limits of the viewer. A zoom of 1 shows the image at its original size.
+const (
+	MinZoom = 0.1
+	MaxZoom = 8.0
+)
+
+// zoomStep is the factor to zoom by for each scroll step or key press.
+const zoomStep = 1.1
+
+// Viewer is a window that shows a full-resolution image. The image can be
+// zoomed by scrolling and panned by dragging.
+type Viewer struct {
+	*adw.ApplicationWindow
+	ctx context.Context
+	url string
+
+	stack   *gtk.Stack
+	scroll  *gtk.ScrolledWindow
+	picture *gtk.Picture
+	fit     *gtk.ToggleButton
+
+	pixbuf *gdkpixbuf.Pixbuf
+	// zoom is the current zoom. It is only used if fitting is disabled.
+	zoom float64
+	// pointer is the last pointer position within the scrolled window.
+	pointer [2]float64
+	// pending is the scroll position to restore once the adjustments are
+	// updated for the new zoom.
+	pending *[2]float64
+}
+
+var _ = cssutil.WriteCSS(`
+	.imageviewer-scroll {
+		background-color: @view_bg_color;
+	}
+	.imageviewer-scroll.imageviewer-panning {
+		cursor: grabbing;
+	}
+`)
+
+// Show calls NewViewer then Show.
+func Show(ctx context.Context, prov imgutil.Provider, url string) *Viewer {
+	v := NewViewer(ctx, prov, url)
+	v.Show()
+	return v
+}
+
+// NewViewer creates a new image viewer window that shows the image at the
+// given URL. The image is fetched using the given Provider at its original
+// size.
+func NewViewer(ctx context.Context, prov imgutil.Provider, url string) *Viewer {
+	v := Viewer{
+		ctx:  ctx,
+		url:  url,
+		zoom: 1,
+	}
+
+	v.picture = gtk.NewPicture()
+	v.picture.AddCSSClass("imageviewer-picture")
+	v.picture.SetHExpand(true)
+	v.picture.SetVExpand(true)
+	v.picture.SetHAlign(gtk.AlignCenter)
+	v.picture.SetVAlign(gtk.AlignCenter)
+	v.picture.SetContentFit(gtk.ContentFitContain)
+
+	v.scroll = gtk.NewScrolledWindow()
+	v.scroll.AddCSSClass("imageviewer-scroll")
+	v.scroll.SetPolicy(gtk.PolicyAutomatic, gtk.PolicyAutomatic)
+	v.scroll.SetChild(v.picture)
+
+	spinner := gtk.NewSpinner()
+	spinner.SetSizeRequest(32, 32)
+	spinner.SetHAlign(gtk.AlignCenter)
+	spinner.SetVAlign(gtk.AlignCenter)
+	spinner.Start()
+
+	errorPage := adw.NewStatusPage()
+	errorPage.SetIconName("image-missing-symbolic")
+	errorPage.SetTitle(locale.Get("Cannot Load Image"))
+
+	v.stack = gtk.NewStack()
+	v.stack.SetTransitionType(gtk.StackTransitionTypeCrossfade)
+	v.stack.AddNamed(spinner, "loading")
+	v.stack.AddNamed(v.scroll, "image")
+	v.stack.AddNamed(errorPage, "error")
+	v.stack.SetVisibleChildName("loading")
+
+	v.fit = gtk.NewToggleButton()
+	v.fit.SetIconName("zoom-fit-best-symbolic")
+	v.fit.SetTooltipText(locale.Get("Fit to Window"))
+	v.fit.SetActive(true)
+	v.fit.ConnectToggled(func() { v.SetFit(v.fit.Active()) })
+
+	copyButton := gtk.NewButtonFromIconName("edit-copy-symbolic")
+	copyButton.SetTooltipText(locale.Get("Copy Image"))
+	copyButton.SetActionName("win.copy")
+
+	saveButton := gtk.NewButtonFromIconName("document-save-as-symbolic")
+	saveButton.SetTooltipText(locale.Get("Save Image As…"))
+	saveButton.SetActionName("win.save")
+
+	header := adw.NewHeaderBar()
+	header.PackStart(v.fit)
+	header.PackEnd(saveButton)
+	header.PackEnd(copyButton)
+
+	toolbar := adw.NewToolbarView()
+	toolbar.AddTopBar(header)
+	toolbar.SetContent(v.stack)
+
+	win := app.GTKWindowFromContext(ctx)
+	app := app.FromContext(ctx)
+
+	v.ApplicationWindow = adw.NewApplicationWindow(app.Application)
+	v.ApplicationWindow.AddCSSClass("imageviewer")
+	v.ApplicationWindow.SetTransientFor(win)
+	v.ApplicationWindow.SetModal(true)
+	v.ApplicationWindow.SetHideOnClose(false)
+	v.ApplicationWindow.SetDestroyWithParent(true)
+	v.ApplicationWindow.SetTitle(imgutil.ImageName(url))
+	v.ApplicationWindow.SetDefaultSize(800, 600)
+	v.ApplicationWindow.SetContent(toolbar)
+
+	v.bindControllers()
+
+	gtkutil.AddActions(v, map[string]func(){
+		"close":    func() { v.Close() },
+		"copy":     func() { v.CopyImage() },
+		"save":     func() { v.SaveAs() },
+		"zoom-in":  func() { v.zoomBy(zoomStep, false) },
+		"zoom-out": func() { v.zoomBy(1/zoomStep, false) },
+		"zoom-fit": func() { v.fit.SetActive(!v.fit.Active()) },
+		"zoom-1x":  func() { v.SetZoom(1) },
+	})
+	gtkutil.AddDescribedActionShortcuts(v, "Image Viewer", []gtkutil.Shortcut{
+		{Accel: "<Control>c", Description: "Copy Image", Action: "win.copy"},
+		{Accel: "<Control>s", Description: "Save Image", Action: "win.save"},
+		{Accel: "plus", Description: "Zoom In", Action: "win.zoom-in"},
+		{Accel: "equal", Description: "Zoom In", Action: "win.zoom-in"},
+		{Accel: "<Control>plus", Description: "Zoom In", Action: "win.zoom-in"},
+		{Accel: "minus", Description: "Zoom Out", Action: "win.zoom-out"},
+		{Accel: "<Control>minus", Description: "Zoom Out", Action: "win.zoom-out"},
+		{Accel: "f", Description: "Toggle Fit to Window", Action: "win.zoom-fit"},
+		{Accel: "1", Description: "Original Size", Action: "win.zoom-1x"},
+	})
+	gtkutil.BindClose(v)
+	gtkutil.DescribeShortcuts("Image Viewer", map[string]locale.Localized{
+		"Escape": "Close",
+	})
+
+	ctx = imgutil.WithOpts(ctx,
+		imgutil.WithErrorFn(func(err error) {
+			errorPage.SetDescription(err.Error())
+			v.stack.SetVisibleChildName("error")
+		}),
+	)
+
+	imgutil.DoProviderURL(ctx, prov, url, imgutil.ImageSetter{
+		SetFromPixbuf: v.setPixbuf,
+		SetFromAnimation: func(anim *gdkpixbuf.PixbufAnimation) {
+			v.setPixbuf(anim.StaticImage())
+		},
+	})
+
+	return &v
+}
+
+func (v *Viewer) bindControllers() {
+	motion := gtk.NewEventControllerMotion()
+	motion.ConnectMotion(func(x, y float64) { v.pointer = [2]float64{x, y} })
+	v.scroll.AddController(motion)
+
+	scroll := gtk.NewEventControllerScroll(gtk.EventControllerScrollVertical)
+	scroll.SetPropagationPhase(gtk.PhaseCapture)
+	scroll.ConnectScroll(func(dx, dy float64) bool {
+		if v.pixbuf == nil || dy == 0 {
+			return false
+		}
+		v.zoomBy(math.Pow(zoomStep, -dy), true)
+		return true
+	})
+	v.scroll.AddController(scroll)
+
+	var dragStart [2]float64
+
+	drag := gtk.NewGestureDrag()
+	drag.ConnectDragBegin(func(x, y float64) {
+		v.pending = nil
+		dragStart = [2]float64{
+			v.scroll.HAdjustment().Value(),
+			v.scroll.VAdjustment().Value(),
+		}
+		v.scroll.AddCSSClass("imageviewer-panning")
+	})
+	drag.ConnectDragUpdate(func(dx, dy float64) {
+		v.scroll.HAdjustment().SetValue(dragStart[0] - dx)
+		v.scroll.VAdjustment().SetValue(dragStart[1] - dy)
+	})
+	drag.ConnectDragEnd(func(dx, dy float64) {
+		v.scroll.RemoveCSSClass("imageviewer-panning")
+	})
+	v.scroll.AddController(drag)
+
+	var zoomStart float64
+
+	pinch := gtk.NewGestureZoom()
+	pinch.ConnectBegin(func(*gdk.EventSequence) { zoomStart = v.currentZoom() })
+	pinch.ConnectScaleChanged(func(scale float64) { v.SetZoom(zoomStart * scale) })
+	v.scroll.AddController(pinch)
+
+	// Restore the scroll position once the adjustments are updated for the
+	// new zoom, since setting them right away would clamp them to the old
+	// size.
+	hadj := v.scroll.HAdjustment()
+	vadj := v.scroll.VAdjustment()
+	restore := func() {
+		if v.pending != nil {
+			hadj.SetValue(v.pending[0])
+			vadj.SetValue(v.pending[1])
+		}
+	}
+	hadj.ConnectChanged(restore)
+	vadj.ConnectChanged(func() {
+		restore()
+		v.pending = nil
+	})
+}
+
+func (v *Viewer) setPixbuf(pixbuf *gdkpixbuf.Pixbuf) {
+	v.pixbuf = pixbuf
+	v.picture.SetPixbuf(pixbuf)
+	v.stack.SetVisibleChildName("image")
+	v.update()
+}
+
+// Pixbuf returns the loaded image, or nil if it's not loaded yet.
+func (v *Viewer) Pixbuf() *gdkpixbuf.Pixbuf {
+	return v.pixbuf
+}
+
+// SetFit sets whether the image should be fitted into the window. If true,
+// the image is scaled down to fit the window. Otherwise, the image is shown
+// at the current zoom.
+func (v *Viewer) SetFit(fit bool) {
+	if v.fit.Active() != fit {
+		// This calls SetFit again through the toggled signal.
+		v.fit.SetActive(fit)
+		return
+	}
+	v.update()
+}
+
+// SetZoom sets the zoom of the image, where 1 is its original size. Fitting is
+// disabled.
+func (v *Viewer) SetZoom(zoom float64) {
+	v.setZoom(zoom, false)
+}
+
+func (v *Viewer) zoomBy(factor float64, atPointer bool) {
+	v.setZoom(v.currentZoom()*factor, atPointer)
+}
+
+func (v *Viewer) setZoom(zoom float64, atPointer bool) {
+	zoom = math.Max(MinZoom, math.Min(MaxZoom, zoom))
+	old := v.currentZoom()
+
+	// Keep the point under the pointer (or the center) still while zooming.
+	anchor := [2]float64{
+		float64(v.scroll.Width()) / 2,
+		float64(v.scroll.Height()) / 2,
+	}
+	if atPointer {
+		anchor = v.pointer
+	}
+
+	hadj := v.scroll.HAdjustment()
+	vadj := v.scroll.VAdjustment()
+	ratio := zoom / old
+
+	v.pending = &[2]float64{
+		(hadj.Value()+anchor[0])*ratio - anchor[0],
+		(vadj.Value()+anchor[1])*ratio - anchor[1],
+	}
+
+	v.zoom = zoom
+	if v.fit.Active() {
+		v.fit.SetActive(false)
+	} else {
+		v.update()
+	}
+}
+
+// currentZoom returns the zoom that the image is currently shown at.
+func (v *Viewer) currentZoom() float64 {
+	if !v.fit.Active() || v.pixbuf == nil {
+		return v.zoom
+	}
+
+	w := float64(v.scroll.Width()) / float64(v.pixbuf.Width())
+	h := float64(v.scroll.Height()) / float64(v.pixbuf.Height())
+	return math.Min(1, math.Min(w, h))
+}
+
+func (v *Viewer) update() {
+	if v.pixbuf == nil {
+		return
+	}
+
+	if v.fit.Active() {
+		v.picture.SetCanShrink(true)
+		v.picture.SetSizeRequest(-1, -1)
+		return
+	}
+
+	v.picture.SetCanShrink(false)
+	v.picture.SetSizeRequest(
+		int(math.Round(float64(v.pixbuf.Width())*v.zoom)),
+		int(math.Round(float64(v.pixbuf.Height())*v.zoom)),
+	)
+}
+
+// CopyImage copies the loaded image into the clipboard.
+func (v *Viewer) CopyImage() {
+	if v.pixbuf == nil {
+		return
+	}
+
+	clipboard := v.ApplicationWindow.Clipboard()
+	clipboard.SetTexture(gdk.NewTextureForPixbuf(v.pixbuf))
+}
+
+// SaveAs prompts the user for a file to save the loaded image into. The image
+// is saved as a PNG.
+func (v *Viewer) SaveAs() {
+	pixbuf := v.pixbuf
+	if pixbuf == nil {
+		return
+	}
+
+	fileDialog := gtk.NewFileDialog()
+	fileDialog.SetTitle(app.FromContext(v.ctx).SuffixedTitle(locale.Get("Save Image")))
+	fileDialog.SetInitialName(imgutil.ImageName(v.url) + ".png")
+	fileDialog.Save(context.Background(), &v.ApplicationWindow.Window, func(async gio.AsyncResulter) {
+		file, err := fileDialog.SaveFinish(async)
+		if err != nil {
+			return
+		}
+
+		filePath := file.Path()
+		if filePath == "" {
+			app.Error(v.ctx, fmt.Errorf("failed to save image: no file path"))
+			return
+		}
+
+		go func() {
+			data, err := imgutil.EncodePNG(pixbuf)
+			if err == nil {
+				err = osutil.WriteFile(filePath, data)
+			}
+			if err != nil {
+				glib.IdleAdd(func() {
+					app.Error(v.ctx, fmt.Errorf("failed to save image: %w", err))
+				})
+			}
+		}()
+	})
+}
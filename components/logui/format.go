@@ -0,0 +1,69 @@
+package logui
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// formatValue formats the given attribute value for displaying in the viewer.
+// Unlike slog.Value.String, groups and JSON-able values are pretty-printed
+// across multiple lines.
+func formatValue(v slog.Value) string {
+	var b strings.Builder
+	writeValue(&b, v, "")
+	return b.String()
+}
+
+func writeValue(b *strings.Builder, v slog.Value, indent string) {
+	v = v.Resolve()
+
+	switch v.Kind() {
+	case slog.KindGroup:
+		for _, attr := range v.Group() {
+			b.WriteString("\n")
+			b.WriteString(indent + "  ")
+			b.WriteString(attr.Key)
+			b.WriteString(" = ")
+			writeValue(b, attr.Value, indent+"  ")
+		}
+	case slog.KindTime:
+		b.WriteString(displayTime(v.Time()).Format("2006-01-02 15:04:05.000 MST"))
+	case slog.KindDuration:
+		b.WriteString(formatDuration(v.Duration()))
+	case slog.KindAny:
+		b.WriteString(formatAny(v.Any(), indent))
+	default:
+		b.WriteString(v.String())
+	}
+}
+
+func formatDuration(d time.Duration) string {
+	// Sub-millisecond precision is rarely useful past a second.
+	if d >= time.Second || d <= -time.Second {
+		d = d.Round(time.Millisecond)
+	}
+	return d.String()
+}
+
+func formatAny(v any, indent string) string {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	case []byte:
+		return string(v)
+	}
+
+	b, err := json.MarshalIndent(v, indent, "  ")
+	if err != nil || string(b) == "{}" || string(b) == "null" {
+		// Values with no exported fields marshal into an empty object, which
+		// is less useful than what fmt gives us.
+		return fmt.Sprint(v)
+	}
+
+	return string(b)
+}
@@ -2,31 +2,30 @@ package logui
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
-	"os"
 	"strings"
+	"time"
 
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
-	"github.com/diamondburned/gotk4/pkg/gdk/v4"
 	"github.com/diamondburned/gotk4/pkg/gio/v2"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotkit/app"
 	"github.com/diamondburned/gotkit/app/locale"
-	"github.com/diamondburned/gotkit/components/autoscroll"
+	"github.com/diamondburned/gotkit/app/prefs"
 	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
 
 	"github.com/diamondburned/gotk4/pkg/core/gioutil"
-	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
 )
 
-// Viewer is a TextView dialog that views a particular log buffer in real time.
+// Viewer is a dialog that views a particular log buffer in real time. It wraps
+// a Widget in its own window.
 type Viewer struct {
 	*adw.ApplicationWindow
-	View  *gtk.ColumnView
-	Model *LogListModel
+	Widget *Widget
+	View   *gtk.ColumnView // same as Widget.View
+	Model  *LogListModel   // same as Widget.Model
 
 	ctx context.Context
 }
@@ -36,6 +35,9 @@ func ShowDefaultViewer(ctx context.Context) {
 	viewer := NewDefaultViewer(ctx)
 	viewer.SetHideOnClose(false)
 	viewer.SetDestroyWithParent(true)
+	if win := app.GTKWindowFromContext(ctx); win != nil {
+		gtkutil.RestoreFocusOnClose(viewer, win)
+	}
 	viewer.Show()
 }
 
@@ -68,48 +70,72 @@ var _ = cssutil.WriteCSS(`
 		margin-right: 0.5em;
 		margin-left: 1em;
 	}
+	.logui-message-attrs-key:dir(rtl) {
+		margin-right: 1em;
+		margin-left: 0.5em;
+	}
+	.logui-message-attrs-source {
+		opacity: 0.75;
+	}
 
-	.logui-dark .logui-level-debug { color: #9fa8da; }
-	.logui-dark .logui-level-info  { color: #a5d6a7; }
-	.logui-dark .logui-level-warn  { color: #ffcc80; }
-	.logui-dark .logui-level-error { color: #ef9a9a; }
+	.logui-dark .logui-level-debug { color: {$logui_level_debug_dark}; }
+	.logui-dark .logui-level-info  { color: {$logui_level_info_dark}; }
+	.logui-dark .logui-level-warn  { color: {$logui_level_warn_dark}; }
+	.logui-dark .logui-level-error { color: {$logui_level_error_dark}; }
 
-	.logui-light .logui-level-debug { color: #1a237e; }
-	.logui-light .logui-level-info  { color: #004d40; }
-	.logui-light .logui-level-warn  { color: #e65100; }
-	.logui-light .logui-level-error { color: #b71c1c; }
+	.logui-light .logui-level-debug { color: {$logui_level_debug_light}; }
+	.logui-light .logui-level-info  { color: {$logui_level_info_light}; }
+	.logui-light .logui-level-warn  { color: {$logui_level_warn_light}; }
+	.logui-light .logui-level-error { color: {$logui_level_error_light}; }
+`)
+
+// The default colors of each log level. The _hc variables are used instead
+// while the desktop requests high contrast. They can be overridden using
+// cssutil.AddCSSVariables before the global CSS is applied.
+func init() {
+	cssutil.AddDefaultCSSVariables(map[string]string{
+		"logui_level_debug_dark": "#9fa8da",
+		"logui_level_info_dark":  "#a5d6a7",
+		"logui_level_warn_dark":  "#ffcc80",
+		"logui_level_error_dark": "#ef9a9a",
+
+		"logui_level_debug_light": "#1a237e",
+		"logui_level_info_light":  "#004d40",
+		"logui_level_warn_light":  "#e65100",
+		"logui_level_error_light": "#b71c1c",
+
+		"logui_level_debug_dark_hc": "#c5cae9",
+		"logui_level_info_dark_hc":  "#c8e6c9",
+		"logui_level_warn_dark_hc":  "#ffe0b2",
+		"logui_level_error_dark_hc": "#ffcdd2",
+
+		"logui_level_debug_light_hc": "#000051",
+		"logui_level_info_light_hc":  "#00251a",
+		"logui_level_warn_light_hc":  "#7f2a00",
+		"logui_level_error_light_hc": "#7f0000",
+	})
+}
+
+var _ = cssutil.WriteHighContrastCSS(`
+	.logui-dark .logui-level-debug { color: {$logui_level_debug_dark_hc}; }
+	.logui-dark .logui-level-info  { color: {$logui_level_info_dark_hc}; }
+	.logui-dark .logui-level-warn  { color: {$logui_level_warn_dark_hc}; }
+	.logui-dark .logui-level-error { color: {$logui_level_error_dark_hc}; }
+
+	.logui-light .logui-level-debug { color: {$logui_level_debug_light_hc}; }
+	.logui-light .logui-level-info  { color: {$logui_level_info_light_hc}; }
+	.logui-light .logui-level-warn  { color: {$logui_level_warn_light_hc}; }
+	.logui-light .logui-level-error { color: {$logui_level_error_light_hc}; }
 `)
 
 // NewViewer creates a new log viewer dialog.
 func NewViewer(ctx context.Context, model *LogListModel) *Viewer {
-	v := Viewer{Model: model, ctx: ctx}
-
-	treeModel := newLogTreeListModel(model)
-
-	view := gtk.NewColumnView(gtk.NewNoSelection(treeModel))
-	view.AddCSSClass("logui-column-view")
-	view.SetShowRowSeparators(false)
-	view.SetShowColumnSeparators(false)
-	// view.SetEnableRubberband(true)
-	view.SetHExpand(true)
-	view.SetVExpand(true)
-	view.SetSizeRequest(500, -1)
-	view.SetObjectProperty("header-factory", (*coreglib.Object)(nil))
-	view.AppendColumn(gtk.NewColumnViewColumn("Time", newTimeColumnFactory()))
-	view.AppendColumn(gtk.NewColumnViewColumn("Level", newLevelColumnFactory()))
-	msgColumn := gtk.NewColumnViewColumn("Message", newMessageColumnFactory())
-	msgColumn.SetExpand(true)
-	view.AppendColumn(msgColumn)
-
-	v.View = view
-
-	scroll := autoscroll.NewWindow()
-	scroll.SetPlacement(gtk.CornerTopLeft)
-	scroll.SetPolicy(gtk.PolicyAutomatic, gtk.PolicyAutomatic)
-	scroll.SetPropagateNaturalWidth(true)
-	scroll.SetPropagateNaturalHeight(true)
-	scroll.SetChild(view)
-	scroll.ScrollToBottom()
+	v := Viewer{ctx: ctx}
+
+	v.Widget = NewWidget(ctx, model)
+	v.Widget.View.SetSizeRequest(500, -1)
+	v.View = v.Widget.View
+	v.Model = v.Widget.Model
 
 	copyButton := gtk.NewButtonFromIconName("edit-copy-symbolic")
 	copyButton.SetTooltipText(locale.Get("Copy logs"))
@@ -125,7 +151,7 @@ func NewViewer(ctx context.Context, model *LogListModel) *Viewer {
 
 	toolbar := adw.NewToolbarView()
 	toolbar.AddTopBar(header)
-	toolbar.SetContent(scroll)
+	toolbar.SetContent(v.Widget)
 
 	win := app.GTKWindowFromContext(ctx)
 	app := app.FromContext(ctx)
@@ -140,70 +166,39 @@ func NewViewer(ctx context.Context, model *LogListModel) *Viewer {
 	v.ApplicationWindow.SetDefaultSize(500, 400)
 	v.ApplicationWindow.SetContent(toolbar)
 
-	styles := adw.StyleManagerGetDefault()
-	updateDark := func() {
-		if styles.Dark() {
-			v.ApplicationWindow.AddCSSClass("logui-dark")
-			v.ApplicationWindow.RemoveCSSClass("logui-light")
-		} else {
-			v.ApplicationWindow.AddCSSClass("logui-light")
-			v.ApplicationWindow.RemoveCSSClass("logui-dark")
-		}
-	}
-	updateDark()
-
-	darkSignal := styles.NotifyProperty("dark", updateDark)
-	v.ApplicationWindow.ConnectDestroy(func() { styles.HandlerDisconnect(darkSignal) })
-
 	gtkutil.AddActions(v, map[string]func(){
 		"close": func() { v.Close() },
-		"copy":  func() { v.copyAll() },
-		"save":  func() { v.saveAs() },
+		"copy":  func() { v.Widget.CopyAll() },
+		"save":  func() { v.Widget.SaveAs(&v.ApplicationWindow.Window) },
+	})
+	gtkutil.AddDescribedActionShortcuts(v, "Log Viewer", []gtkutil.Shortcut{
+		{Accel: "<Control>c", Description: "Copy All Logs", Action: "win.copy"},
+		{Accel: "<Control>s", Description: "Save Logs", Action: "win.save"},
 	})
-	gtkutil.AddActionShortcuts(v, map[string]string{
-		"Escape":     "win.close",
-		"<Control>c": "win.copy",
-		"<Control>s": "win.save",
+	gtkutil.BindClose(v)
+	gtkutil.DescribeShortcuts("Log Viewer", map[string]locale.Localized{
+		"Escape": "Close",
 	})
 
 	return &v
 }
 
-func (v *Viewer) copyAll() {
-	// TODO: copy only the selected items
-
-	content := RecordsToString(v.Model.All())
-
-	display := gdk.DisplayGetDefault()
-
-	clipboard := display.Clipboard()
-	clipboard.SetText(content)
-}
-
-func (v *Viewer) saveAs() {
-	content := RecordsToString(v.Model.All())
-
-	fileDialog := gtk.NewFileDialog()
-	fileDialog.SetTitle(app.FromContext(v.ctx).SuffixedTitle(locale.Get("Save Logs")))
-	fileDialog.SetInitialName("dissent-logs.txt")
-	fileDialog.Save(context.Background(), &v.ApplicationWindow.Window, func(async gio.AsyncResulter) {
-		file, err := fileDialog.SaveFinish(async)
-		if err != nil {
-			return
-		}
-
-		filePath := file.Path()
-		if filePath == "" {
-			app.Error(v.ctx, fmt.Errorf("failed to save logs: no file path"))
-			return
-		}
-
-		go func() {
-			if err := os.WriteFile(filePath, []byte(content), 0640); err != nil {
-				app.Error(v.ctx, fmt.Errorf("failed to save logs: %w", err))
-			}
-		}()
-	})
+// ShowUTCTime is a preference that makes the log viewer show the time of each
+// record in UTC instead of the local timezone.
+var ShowUTCTime = prefs.NewBool(false, prefs.PropMeta{
+	Name:    "Show Log Times in UTC",
+	Section: "Application",
+	Description: "Show the times of log records in UTC instead of the local " +
+		"timezone. This is useful when comparing logs across machines.",
+})
+
+// displayTime returns t in the timezone that the viewer should display it in.
+// Records may carry different zones, so this keeps the times consistent.
+func displayTime(t time.Time) time.Time {
+	if ShowUTCTime.Value() {
+		return t.UTC()
+	}
+	return t.Local()
 }
 
 func newTimeColumnFactory() *gtk.ListItemFactory {
@@ -211,6 +206,8 @@ func newTimeColumnFactory() *gtk.ListItemFactory {
 	factory.ConnectSetup(func(obj *glib.Object) {
 		label := gtk.NewLabel("")
 		label.AddCSSClass("logui-time")
+		// GtkLabel flips the xalign in RTL, so this keeps the time aligned
+		// towards the level column either way.
 		label.SetXAlign(1)
 		label.SetYAlign(0)
 
@@ -232,8 +229,9 @@ func newTimeColumnFactory() *gtk.ListItemFactory {
 			expander.SetObjectProperty("hide-expander", record.NumAttrs() == 0)
 
 			label := expander.Child().(*gtk.Label)
-			label.SetText(record.Time.Format("15:04:05.000"))
-			label.SetTooltipText(locale.Time(record.Time, true))
+			t := displayTime(record.Time)
+			label.SetText(t.Format("15:04:05.000"))
+			label.SetTooltipText(locale.Time(t, true))
 
 			item.SetSelectable(true)
 		default:
@@ -322,7 +320,6 @@ func newMessageColumnFactory() *gtk.ListItemFactory {
 
 			grid := gtk.NewGrid()
 			grid.AddCSSClass("logui-message-attrs")
-			grid.SetRowHomogeneous(true)
 			grid.SetColumnSpacing(2)
 
 			var row int
@@ -331,12 +328,19 @@ func newMessageColumnFactory() *gtk.ListItemFactory {
 				key.AddCSSClass("logui-message-attrs-key")
 				key.SetWrap(false)
 				key.SetXAlign(0)
+				key.SetYAlign(0)
 
-				value := gtk.NewLabel("= " + attr.Value.String())
+				value := gtk.NewLabel("= " + formatValue(attr.Value))
 				value.AddCSSClass("logui-message-attrs-value")
 				value.SetWrap(false)
 				value.SetXAlign(0)
 
+				if attr.Key == slog.SourceKey {
+					// Allow the source location to be selected and copied.
+					value.AddCSSClass("logui-message-attrs-source")
+					value.SetSelectable(true)
+				}
+
 				grid.Attach(key, 0, row, 1, 1)
 				grid.Attach(value, 1, row, 1, 1)
 
@@ -14,6 +14,7 @@ func Example() {
 	app2 := app.New(context.Background(), "com.github.diamondburned.gotkit.components.logui", "logui")
 	app2.ConnectActivate(func() {
 		adw.Init()
+		logui.Hook()
 
 		slog.Debug("example debug message", "key", "value")
 		slog.Info("example info message", "key", "value")
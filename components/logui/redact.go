@@ -0,0 +1,82 @@
+package logui
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// RedactedValue is the value that replaces the values of redacted attributes.
+const RedactedValue = "***"
+
+var redactedKeys = struct {
+	sync.RWMutex
+	keys []string
+}{
+	keys: []string{"password", "token", "authorization", "secret"},
+}
+
+// RedactKeys registers the given key patterns as sensitive. An attribute whose
+// key contains any of the patterns, ignoring case, has its value replaced with
+// RedactedValue before it's stored by a LogHandler or exported by
+// RecordsToString. This prevents credentials from leaking when users share
+// their logs. By default, "password", "token", "authorization" and "secret"
+// are registered.
+func RedactKeys(patterns ...string) {
+	redactedKeys.Lock()
+	defer redactedKeys.Unlock()
+
+	for _, pattern := range patterns {
+		redactedKeys.keys = append(redactedKeys.keys, strings.ToLower(pattern))
+	}
+}
+
+// IsRedactedKey returns true if the given attribute key matches any of the
+// patterns registered using RedactKeys.
+func IsRedactedKey(key string) bool {
+	key = strings.ToLower(key)
+
+	redactedKeys.RLock()
+	defer redactedKeys.RUnlock()
+
+	for _, pattern := range redactedKeys.keys {
+		if strings.Contains(key, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactAttr returns attr with its value replaced with RedactedValue if its key
+// is sensitive, as registered using RedactKeys. Group attributes are redacted
+// recursively. It has the signature of slog.HandlerOptions.ReplaceAttr, so it
+// can be used there directly.
+func RedactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if IsRedactedKey(attr.Key) {
+		return slog.String(attr.Key, RedactedValue)
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		group := attr.Value.Group()
+		groups := append(groups[:len(groups):len(groups)], attr.Key)
+
+		redacted := make([]slog.Attr, len(group))
+		for i, a := range group {
+			redacted[i] = RedactAttr(groups, a)
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(redacted...)}
+	}
+
+	return attr
+}
+
+// redactRecord returns a copy of record with all of its attributes passed
+// through RedactAttr.
+func redactRecord(record slog.Record) slog.Record {
+	redacted := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(RedactAttr(nil, attr))
+		return true
+	})
+	return redacted
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/diamondburned/gotk4/pkg/core/gioutil"
 	"github.com/lmittmann/tint"
@@ -30,6 +33,37 @@ func SetLogger(logger *slog.Logger) {
 	slog.SetDefault(WrapLogger(logger))
 }
 
+// Hook installs the default log handler into the default slog.Logger, so that
+// the logs show up in the log viewer. Any existing handler is kept and wrapped
+// using MultiHandler, the same as SetLogger(slog.Default()). Unlike SetLogger,
+// Hook does nothing if the default logger already writes to the default log
+// handler, so it may be called any number of times. This package does so on
+// init, so Hook is only needed after slog.SetDefault was called elsewhere.
+//
+// Call this function only once the main loop is running.
+func Hook() {
+	logger := slog.Default()
+	if hasHandler(logger.Handler(), DefaultLogHandler()) {
+		return
+	}
+	SetLogger(logger)
+}
+
+// hasHandler returns true if h is target or a MultiHandler that contains it.
+func hasHandler(h slog.Handler, target *LogHandler) bool {
+	switch h := h.(type) {
+	case *LogHandler:
+		return h == target
+	case multiHandler:
+		for _, h := range h {
+			if hasHandler(h, target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func init() {
 	handler := MultiHandler(
 		tint.NewHandler(os.Stderr, &tint.Options{
@@ -43,10 +77,14 @@ func init() {
 }
 
 // RecordsToString returns a string representation of the given log records.
+// Sensitive attributes are redacted using RedactAttr.
 func RecordsToString(iter func(yield func(slog.Record) bool)) string {
 	var text strings.Builder
 
-	h := slog.NewTextHandler(&text, &slog.HandlerOptions{Level: slog.LevelDebug})
+	h := slog.NewTextHandler(&text, &slog.HandlerOptions{
+		Level:       slog.LevelDebug,
+		ReplaceAttr: RedactAttr,
+	})
 	iter(func(record slog.Record) bool {
 		h.Handle(context.Background(), record)
 		return true
@@ -60,10 +98,13 @@ type LogListModel = gioutil.ListModel[slog.Record]
 var LogListModelType = gioutil.NewListModelType[slog.Record]()
 
 // LogHandler is a slog.Handler that stores logs in a list model.
-// To obtain the list model, use the [ListModel] method.
+// To obtain the list model, use the [ListModel] method. Sensitive attributes,
+// as registered using RedactKeys, are redacted before they're stored.
 type LogHandler struct {
-	level *atomic.Pointer[slog.Leveler]
-	list  *LogListModel
+	level     *atomic.Pointer[slog.Leveler]
+	addSource *atomic.Bool
+	list      *LogListModel
+	batch     *logBatch
 
 	attrs  []slog.Attr
 	groups string
@@ -74,13 +115,18 @@ var _ slog.Handler = (*LogHandler)(nil)
 
 // NewLogHandler creates a new LogHandler with the given options.
 // If maxEntries is 0, then the list model will have no limit.
+// If opts.AddSource is true, then the call site of each record is resolved and
+// stored as the slog.SourceKey attribute.
 func NewLogHandler(maxEntries int, opts *slog.HandlerOptions) *LogHandler {
 	h := &LogHandler{
-		level: new(atomic.Pointer[slog.Leveler]),
-		list:  LogListModelType.New(),
+		level:     new(atomic.Pointer[slog.Leveler]),
+		addSource: new(atomic.Bool),
+		list:      LogListModelType.New(),
+		batch:     new(logBatch),
 	}
 	h.max.Store(int32(maxEntries))
 	h.level.Store(&opts.Level)
+	h.addSource.Store(opts.AddSource)
 	return h
 }
 
@@ -124,6 +170,19 @@ func (h *LogHandler) SetLevel(level slog.Leveler) {
 	h.level.Store(&level)
 }
 
+// AddSource returns whether the handler records the source location of each
+// record. This method is thread-safe.
+func (h *LogHandler) AddSource() bool {
+	return h.addSource.Load()
+}
+
+// SetAddSource sets whether the handler records the source location of each
+// record. Resolving the source location is fairly expensive, so it is disabled
+// by default. This method is thread-safe.
+func (h *LogHandler) SetAddSource(addSource bool) {
+	h.addSource.Store(addSource)
+}
+
 // MaxEntries returns the maximum number of log entries.
 func (h *LogHandler) MaxEntries() int {
 	return int(h.max.Load())
@@ -136,10 +195,12 @@ func (h *LogHandler) SetMaxEntries(n int) {
 
 func (h *LogHandler) clone() *LogHandler {
 	h2 := &LogHandler{
-		level:  h.level,
-		list:   h.list,
-		attrs:  append([]slog.Attr{}, h.attrs...),
-		groups: h.groups,
+		level:     h.level,
+		addSource: h.addSource,
+		list:      h.list,
+		batch:     h.batch,
+		attrs:     append([]slog.Attr{}, h.attrs...),
+		groups:    h.groups,
 	}
 	h2.max.Store(h.max.Load())
 	return h2
@@ -150,31 +211,102 @@ func (h *LogHandler) Enabled(_ context.Context, level slog.Level) bool {
 }
 
 func (h *LogHandler) Handle(_ context.Context, record slog.Record) error {
-	record = record.Clone()
+	record = redactRecord(record)
+	if h.addSource.Load() && record.PC != 0 {
+		record.AddAttrs(slog.String(slog.SourceKey, sourceLocation(record.PC)))
+	}
 	record.AddAttrs(h.attrs...)
 
-	coreglib.IdleAdd(func() {
-		h.list.Append(record)
-
-		max := int(h.max.Load())
-		if max > 0 {
-			n := h.list.Len()
-			if n > max {
-				h.list.Splice(0, n-max)
-			}
-		}
-	})
+	if h.batch.add(record) {
+		coreglib.TimeoutAdd(flushInterval, func() { h.flush() })
+	}
 
 	return nil
 }
 
+const (
+	// flushInterval is the interval in milliseconds at which batched records
+	// are flushed into the list model. It is roughly one frame at 60Hz.
+	flushInterval = 16
+	// maxBatchSize is the maximum number of records that can be batched
+	// within one flush interval. Records past this are dropped, and a summary
+	// record is logged in their place.
+	maxBatchSize = 500
+)
+
+// logBatch buffers records to be appended to the list model, so that a storm
+// of logs doesn't queue an idle callback per record and freeze the UI.
+type logBatch struct {
+	mu      sync.Mutex
+	records []slog.Record
+	dropped int
+	pending bool
+}
+
+// add adds the record into the batch. It returns true if the caller should
+// schedule a flush.
+func (b *logBatch) add(record slog.Record) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.records) >= maxBatchSize {
+		b.dropped++
+	} else {
+		b.records = append(b.records, record)
+	}
+
+	if b.pending {
+		return false
+	}
+
+	b.pending = true
+	return true
+}
+
+// take takes all records out of the batch.
+func (b *logBatch) take() (records []slog.Record, dropped int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	records, dropped = b.records, b.dropped
+	b.records = nil
+	b.dropped = 0
+	b.pending = false
+	return
+}
+
+func (h *LogHandler) flush() {
+	records, dropped := h.batch.take()
+	if dropped > 0 {
+		summary := slog.NewRecord(time.Now(), slog.LevelWarn, "too many log records, some were dropped", 0)
+		summary.AddAttrs(slog.String("module", "logui"), slog.Int("dropped", dropped))
+		records = append(records, summary)
+	}
+
+	max := int(h.max.Load())
+	if max > 0 && len(records) > max {
+		// Don't bother adding records that would be removed right away.
+		records = records[len(records)-max:]
+	}
+
+	n := h.list.Len()
+	h.list.Splice(n, 0, records...)
+
+	if max > 0 {
+		n := h.list.Len()
+		if n > max {
+			h.list.Splice(0, n-max)
+		}
+	}
+}
+
 func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	h = h.clone()
 	for _, attr := range attrs {
-		h.attrs = append(h.attrs, slog.Attr{
+		h.attrs = append(h.attrs, RedactAttr(nil, slog.Attr{
 			Key:   joinGroups(h.groups, attr.Key),
 			Value: attr.Value,
-		})
+		}))
 	}
 	return h
 }
@@ -185,6 +317,18 @@ func (h *LogHandler) WithGroup(name string) slog.Handler {
 	return h
 }
 
+// sourceLocation resolves pc into a short "dir/file.go:line" string.
+func sourceLocation(pc uintptr) string {
+	frames := runtime.CallersFrames([]uintptr{pc})
+	frame, _ := frames.Next()
+	if frame.File == "" {
+		return "unknown"
+	}
+
+	file := filepath.Join(filepath.Base(filepath.Dir(frame.File)), filepath.Base(frame.File))
+	return file + ":" + strconv.Itoa(frame.Line)
+}
+
 func joinGroups(base string, tail string) string {
 	if base == "" {
 		return tail
@@ -0,0 +1,143 @@
+package logui
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/components/autoscroll"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+)
+
+// Widget is an embeddable log view. It shows the records of a LogListModel in
+// a column view that automatically scrolls to the newest record. Unlike
+// Viewer, it can be placed inside any existing layout.
+type Widget struct {
+	*autoscroll.Window
+	View  *gtk.ColumnView
+	Model *LogListModel
+
+	timeColumn *gtk.ColumnViewColumn
+
+	ctx context.Context
+}
+
+var _ = cssutil.WriteCSS(`
+	.logui-compact .logui-column-view row:last-child {
+		margin-bottom: 0;
+	}
+	.logui-compact .logui-column-view cell {
+		padding: 0 2px;
+	}
+	.logui-compact .logui-column-view cell:first-child {
+		padding: 0 6px;
+	}
+`)
+
+// NewWidget creates a new log widget showing the given model.
+func NewWidget(ctx context.Context, model *LogListModel) *Widget {
+	w := Widget{Model: model, ctx: ctx}
+
+	treeModel := newLogTreeListModel(model)
+
+	w.View = gtk.NewColumnView(gtk.NewNoSelection(treeModel))
+	w.View.AddCSSClass("logui-column-view")
+	w.View.SetShowRowSeparators(false)
+	w.View.SetShowColumnSeparators(false)
+	// w.View.SetEnableRubberband(true)
+	w.View.SetHExpand(true)
+	w.View.SetVExpand(true)
+	w.View.SetObjectProperty("header-factory", (*coreglib.Object)(nil))
+
+	w.timeColumn = gtk.NewColumnViewColumn("Time", newTimeColumnFactory())
+	w.View.AppendColumn(w.timeColumn)
+	w.View.AppendColumn(gtk.NewColumnViewColumn("Level", newLevelColumnFactory()))
+	msgColumn := gtk.NewColumnViewColumn("Message", newMessageColumnFactory())
+	msgColumn.SetExpand(true)
+	w.View.AppendColumn(msgColumn)
+
+	w.Window = autoscroll.NewWindow()
+	w.Window.AddCSSClass("logui-widget")
+	w.Window.SetPlacement(gtk.CornerTopLeft)
+	w.Window.SetPolicy(gtk.PolicyAutomatic, gtk.PolicyAutomatic)
+	w.Window.SetPropagateNaturalWidth(true)
+	w.Window.SetPropagateNaturalHeight(true)
+	w.Window.SetChild(w.View)
+	w.Window.ScrollToBottom()
+
+	cssutil.BindThemeClasses(w.Window, "logui-dark", "logui-light")
+
+	showUTC := ShowUTCTime.Value()
+	ShowUTCTime.SubscribeWidget(w, func() {
+		if utc := ShowUTCTime.Value(); utc != showUTC {
+			showUTC = utc
+			// Rebind all rows so that the time column is reformatted.
+			w.View.SetModel(gtk.NewNoSelection(newLogTreeListModel(w.Model)))
+		}
+	})
+
+	return &w
+}
+
+// SetCompact sets whether the widget should be shown in a compact mode. In this
+// mode, each record is shown on a tight single line without its time, which is
+// useful for embedding a small log tail into a panel.
+func (w *Widget) SetCompact(compact bool) {
+	w.timeColumn.SetVisible(!compact)
+	if compact {
+		w.Window.AddCSSClass("logui-compact")
+	} else {
+		w.Window.RemoveCSSClass("logui-compact")
+	}
+}
+
+// CopyAll copies all records in the model into the clipboard.
+func (w *Widget) CopyAll() {
+	// TODO: copy only the selected items
+
+	content := RecordsToString(w.Model.All())
+
+	display := gdk.DisplayGetDefault()
+
+	clipboard := display.Clipboard()
+	clipboard.SetText(content)
+}
+
+// SaveAs prompts the user for a file to save all records in the model into.
+// If parent is nil, then the window from the widget's context is used.
+func (w *Widget) SaveAs(parent *gtk.Window) {
+	if parent == nil {
+		parent = app.GTKWindowFromContext(w.ctx)
+	}
+
+	content := RecordsToString(w.Model.All())
+
+	fileDialog := gtk.NewFileDialog()
+	fileDialog.SetTitle(app.FromContext(w.ctx).SuffixedTitle(locale.Get("Save Logs")))
+	fileDialog.SetInitialName("dissent-logs.txt")
+	fileDialog.Save(context.Background(), parent, func(async gio.AsyncResulter) {
+		file, err := fileDialog.SaveFinish(async)
+		if err != nil {
+			return
+		}
+
+		filePath := file.Path()
+		if filePath == "" {
+			app.Error(w.ctx, fmt.Errorf("failed to save logs: no file path"))
+			return
+		}
+
+		go func() {
+			if err := os.WriteFile(filePath, []byte(content), 0640); err != nil {
+				app.Error(w.ctx, fmt.Errorf("failed to save logs: %w", err))
+			}
+		}()
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/url"
 
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
 	"github.com/diamondburned/gotk4/pkg/gdkpixbuf/v2"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
@@ -13,9 +14,10 @@ import (
 	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
 )
 
-// MaxFPS is the maximum FPS to play an animation (often a GIF) at. In reality,
-// the actual frame rate heavily depends on the draw clock of GTK, but this
-// duration determines the background ticker.
+// MaxFPS is the maximum FPS to play an animation (often a GIF) at. Animations
+// are driven by GTK's frame clock, so the actual frame rate is also bounded by
+// how often the widget is drawn. Frames with a shorter delay than this are
+// slowed down.
 //
 // For more information, see
 // https://wunkolo.github.io/post/2020/02/buttery-smooth-10fps/.
@@ -53,6 +55,8 @@ type baseImage struct {
 
 	scaler    pixbufScaler
 	animation *animation
+	states    *loadStates
+	lazy      *lazyLoad
 
 	ctx gtkutil.Cancellable
 	url string
@@ -61,9 +65,19 @@ type baseImage struct {
 }
 
 type animation struct {
-	pixbuf    *gdkpixbuf.PixbufAnimation
-	animating glib.SourceHandle
-	paused    bool
+	pixbuf *gdkpixbuf.PixbufAnimation
+	// iter is the iterator of the current playback. It is kept while the
+	// animation is paused so that it can be resumed.
+	iter *gdkpixbuf.PixbufAnimationIter
+	// elapsed is the time in microseconds into the animation that iter is at.
+	elapsed int64
+	// animating is the ID of the tick callback playing the animation, or 0 if
+	// the animation isn't playing.
+	animating uint
+	// paused is true if the image is unmapped or its window is unfocused.
+	paused bool
+	// held is true if the animation is paused using Pause.
+	held bool
 }
 
 // NewAvatar creates a new avatar.
@@ -93,6 +107,10 @@ func (b *baseImage) SetFromURL(url string) {
 func (b *baseImage) Disable() {
 	b.url = ""
 	b.off = true
+	if b.lazy != nil {
+		b.cancelLazy()
+	}
+	b.setState(loadIdle)
 	b.scaler.SetFromPixbuf(nil)
 }
 
@@ -108,11 +126,20 @@ func (b *baseImage) fetch(ctx context.Context) {
 
 	url := b.url
 	if url == "" {
+		b.setState(loadIdle)
 		b.setter.SetFromPixbuf(nil)
 		b.scaler.SetFromPixbuf(nil)
 		return
 	}
 
+	b.setState(loadLoading)
+
+	ctx, ok := b.lazyCtx(ctx)
+	if !ok {
+		return
+	}
+	ctx = b.stateCtx(ctx, url)
+
 	imgutil.DoProviderURL(ctx, b.prov, url, imgutil.ImageSetter{
 		SetFromPixbuf: func(p *gdkpixbuf.Pixbuf) {
 			if b.url != url {
@@ -120,10 +147,12 @@ func (b *baseImage) fetch(ctx context.Context) {
 			}
 
 			b.ok = true
+			b.setState(loadDone)
 			b.scaler.SetFromPixbuf(p)
 
 			if b.animation != nil {
 				b.animation.pixbuf = nil
+				b.animation.iter = nil
 			}
 		},
 		SetFromAnimation: func(anim *gdkpixbuf.PixbufAnimation) {
@@ -132,15 +161,30 @@ func (b *baseImage) fetch(ctx context.Context) {
 			}
 
 			b.ok = true
+			b.setState(loadDone)
 			b.scaler.SetFromPixbuf(anim.StaticImage())
 
 			if b.animation != nil {
 				b.animation.pixbuf = anim
+				b.animation.iter = nil
 			}
 		},
 	})
 }
 
+// release drops the loaded image so that its memory can be freed. The image is
+// fetched again once the widget is mapped again.
+func (b *baseImage) release() {
+	b.ok = false
+	b.setter.SetFromPixbuf(nil)
+
+	if b.animation != nil {
+		b.removeAnimationTick()
+		b.animation.pixbuf = nil
+		b.animation.iter = nil
+	}
+}
+
 func (b *baseImage) enableAnimation() *AnimationController {
 	if !CanAnimate {
 		return (*AnimationController)(b)
@@ -211,55 +255,137 @@ const (
 func (b *baseImage) startAnimation() {
 	if b.animation == nil ||
 		b.animation.paused ||
+		b.animation.held ||
 		b.animation.pixbuf == nil ||
 		b.animation.animating != 0 {
 		return
 	}
 
-	iter := b.animation.pixbuf.Iter(nil)
+	anim := b.animation
 	setter := b.imageParent.setter
 
-	scale := b.scale()
-	w, h := b.sizeAllocated()
-	w *= scale
-	h *= scale
-
-	useIter := func(iter *gdkpixbuf.PixbufAnimationIter) {
-		setter.SetFromPixbuf(iter.Pixbuf())
+	if anim.iter == nil {
+		anim.elapsed = 0
+		anim.iter = anim.pixbuf.Iter(animTime(0))
 	}
+
 	// Kickstart the animation.
-	useIter(iter)
-
-	var scheduleNext func()
-	scheduleNext = func() {
-		if delay := animDelay(iter); delay != -1 {
-			// Schedule next frame.
-			b.animation.animating = glib.TimeoutAddPriority(uint(delay), glib.PriorityLow, func() {
-				if iter.Advance(nil) {
-					useIter(iter)
-				}
-				scheduleNext()
-			})
-		} else {
-			// End of animation.
-			b.stopAnimation()
-		}
+	setter.SetFromPixbuf(anim.iter.Pixbuf())
+
+	if animDelay(anim.iter) == -1 {
+		// Single-frame animation.
+		return
 	}
-	// Schedule the next frame.
-	scheduleNext()
+
+	// next is the frame time in microseconds that the next frame is due at.
+	// It is set on the first tick, since the frame clock may not have a time
+	// yet.
+	var next int64
+
+	// Drive the animation using the frame clock, so frames are only advanced
+	// when they're actually drawn. The frame clock doesn't tick while the
+	// widget isn't drawn, and frames are skipped if the clock lags behind.
+	base := gtk.BaseWidget(b.parent)
+	anim.animating = base.AddTickCallback(func(_ gtk.Widgetter, clock gdk.FrameClocker) bool {
+		now := gdk.BaseFrameClock(clock).FrameTime()
+		if next == 0 {
+			next = now + int64(animDelay(anim.iter))*1000
+			return true
+		}
+		if now < next {
+			return true
+		}
+
+		if now-next > maxAnimLag {
+			// Too far behind, so just continue from the next frame.
+			next = now
+		}
+
+		var changed bool
+		for now >= next {
+			// Step the iterator's clock to the end of the current frame.
+			anim.elapsed += int64(max(anim.iter.DelayTime(), 1)) * 1000
+			if anim.iter.Advance(animTime(anim.elapsed)) {
+				changed = true
+			}
+
+			delay := animDelay(anim.iter)
+			if delay == -1 {
+				// End of animation.
+				anim.animating = 0
+				anim.iter = nil
+				b.finishStopAnimation()
+				return false
+			}
+
+			next += int64(delay) * 1000
+		}
+
+		if changed {
+			setter.SetFromPixbuf(anim.iter.Pixbuf())
+		}
+
+		return true
+	})
 }
 
+// maxAnimLag is the maximum time in microseconds that an animation can lag
+// behind before it stops skipping frames to catch up.
+const maxAnimLag = 1_000_000
+
 func (b *baseImage) stopAnimation() {
 	if b.animation == nil || b.animation.animating == 0 {
 		return
 	}
 
+	b.removeAnimationTick()
+	b.animation.iter = nil
+	b.finishStopAnimation()
+}
+
+// pauseAnimation pauses the animation at its current frame until
+// resumeAnimation is called.
+func (b *baseImage) pauseAnimation() {
+	if b.animation == nil {
+		return
+	}
+
+	b.animation.held = true
+	b.removeAnimationTick()
+}
+
+func (b *baseImage) resumeAnimation() {
+	if b.animation == nil {
+		return
+	}
+
+	b.animation.held = false
+	b.startAnimation()
+}
+
+// seekAnimation rewinds the animation to its first frame. The animation keeps
+// playing if it was.
+func (b *baseImage) seekAnimation() {
+	if b.animation == nil {
+		return
+	}
+
+	b.animation.iter = nil
+
 	if b.animation.animating != 0 {
-		glib.SourceRemove(b.animation.animating)
-		b.animation.animating = 0
+		b.removeAnimationTick()
+		b.startAnimation()
+	} else {
+		b.finishStopAnimation()
 	}
+}
 
-	b.finishStopAnimation()
+func (b *baseImage) removeAnimationTick() {
+	if b.animation.animating != 0 {
+		base := gtk.BaseWidget(b.parent)
+		base.RemoveTickCallback(b.animation.animating)
+		b.animation.animating = 0
+	}
 }
 
 func (b *baseImage) finishStopAnimation() {
@@ -271,6 +397,13 @@ func (b *baseImage) finishStopAnimation() {
 	}
 }
 
+// animTime returns the time for an animation iterator that is us microseconds
+// into the animation.
+func animTime(us int64) *glib.TimeVal {
+	t := glib.NewTimeVal(int32(us/1_000_000), int32(us%1_000_000))
+	return &t
+}
+
 func animDelay(iter *gdkpixbuf.PixbufAnimationIter) int {
 	delayMs := iter.DelayTime()
 	if delayMs == -1 {
@@ -0,0 +1,139 @@
+package onlineimage
+
+import (
+	"context"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/gtkutil"
+)
+
+// lazyLoad defers fetching an image until it intersects the viewport of its
+// closest scrolled window.
+type lazyLoad struct {
+	scrolled *gtk.ScrolledWindow
+	visible  bool
+	// cancel cancels the in-flight fetch, if any.
+	cancel context.CancelFunc
+}
+
+func (b *baseImage) enableLazyLoad() {
+	if b.lazy != nil {
+		return
+	}
+
+	b.lazy = &lazyLoad{}
+
+	gtkutil.BindSubscribe(b.parent, func() func() {
+		scrolled := scrolledAncestor(b.parent)
+		if scrolled == nil {
+			// Nothing to scroll, so treat the image as always visible.
+			b.setLazyVisible(true)
+			return func() { b.setLazyVisible(false) }
+		}
+
+		b.lazy.scrolled = scrolled
+
+		hadj := scrolled.HAdjustment()
+		vadj := scrolled.VAdjustment()
+
+		// The content size changes when the list is laid out, which can move
+		// the image without scrolling.
+		hvalue := hadj.ConnectValueChanged(b.updateLazy)
+		vvalue := vadj.ConnectValueChanged(b.updateLazy)
+		hchanged := hadj.ConnectChanged(b.updateLazy)
+		vchanged := vadj.ConnectChanged(b.updateLazy)
+
+		// The image isn't allocated yet when it's mapped, so check once it
+		// is.
+		idle := glib.IdleAdd(b.updateLazy)
+
+		return func() {
+			glib.SourceRemove(idle)
+			hadj.HandlerDisconnect(hvalue)
+			vadj.HandlerDisconnect(vvalue)
+			hadj.HandlerDisconnect(hchanged)
+			vadj.HandlerDisconnect(vchanged)
+
+			b.lazy.scrolled = nil
+			b.setLazyVisible(false)
+		}
+	})
+}
+
+func (b *baseImage) updateLazy() {
+	b.setLazyVisible(b.lazy.scrolled == nil || b.inViewport(b.lazy.scrolled))
+}
+
+func (b *baseImage) setLazyVisible(visible bool) {
+	if b.lazy.visible == visible {
+		return
+	}
+
+	b.lazy.visible = visible
+
+	if visible {
+		b.fetch(b.ctx.Take())
+		return
+	}
+
+	// Stop fetching images that were scrolled away before they finished, so
+	// they don't take up bandwidth. They're fetched again once they're back.
+	if !b.ok {
+		b.cancelLazy()
+	}
+}
+
+// lazyCtx returns the context to fetch the image with. If the image isn't
+// visible yet, then false is returned and nothing should be fetched.
+func (b *baseImage) lazyCtx(ctx context.Context) (context.Context, bool) {
+	if b.lazy == nil {
+		return ctx, true
+	}
+
+	if !b.lazy.visible {
+		return ctx, false
+	}
+
+	b.cancelLazy()
+
+	ctx, cancel := context.WithCancel(ctx)
+	b.lazy.cancel = cancel
+
+	return ctx, true
+}
+
+func (b *baseImage) cancelLazy() {
+	if b.lazy.cancel != nil {
+		b.lazy.cancel()
+		b.lazy.cancel = nil
+	}
+}
+
+// scrolledAncestor returns the closest gtk.ScrolledWindow containing the given
+// widget, or nil if there is none.
+func scrolledAncestor(w gtk.Widgetter) *gtk.ScrolledWindow {
+	for parent := gtk.BaseWidget(w).Parent(); parent != nil; parent = gtk.BaseWidget(parent).Parent() {
+		if scrolled, ok := parent.(*gtk.ScrolledWindow); ok {
+			return scrolled
+		}
+	}
+	return nil
+}
+
+// inViewport returns true if the image is within the visible area of the
+// given scrolled window.
+func (b *baseImage) inViewport(scrolled *gtk.ScrolledWindow) bool {
+	bounds, ok := gtk.BaseWidget(b.parent).ComputeBounds(scrolled)
+	if !ok {
+		return false
+	}
+
+	x, y := bounds.X(), bounds.Y()
+	w, h := bounds.Width(), bounds.Height()
+
+	viewW := float32(scrolled.Width())
+	viewH := float32(scrolled.Height())
+
+	return x <= viewW && y <= viewH && x+w >= 0 && y+h >= 0
+}
@@ -0,0 +1,97 @@
+package onlineimage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gdkpixbuf/v2"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+	"github.com/diamondburned/gotkit/utils/osutil"
+)
+
+// Pixbuf returns the currently loaded image at its original size, or nil if
+// no image is loaded yet. For animations, only the static image is returned.
+func (b *baseImage) Pixbuf() *gdkpixbuf.Pixbuf {
+	return b.scaler.src
+}
+
+func (b *baseImage) enableContextMenu() {
+	gtkutil.BindActionMap(b.parent, map[string]func(){
+		"onlineimage.copy":     b.copyImage,
+		"onlineimage.copy-url": b.copyURL,
+		"onlineimage.save-as":  b.saveAs,
+	})
+	gtkutil.BindPopoverMenuAtMouse(b.parent, gtk.PosBottom, [][2]string{
+		{locale.Get("Copy Image"), "onlineimage.copy"},
+		{locale.Get("Copy Image URL"), "onlineimage.copy-url"},
+		{locale.Get("Save Image As…"), "onlineimage.save-as"},
+	})
+}
+
+func (b *baseImage) copyImage() {
+	pixbuf := b.Pixbuf()
+	if pixbuf == nil {
+		return
+	}
+
+	clipboard := gtk.BaseWidget(b.parent).Clipboard()
+	clipboard.SetTexture(gdk.NewTextureForPixbuf(pixbuf))
+}
+
+func (b *baseImage) copyURL() {
+	if b.url == "" {
+		return
+	}
+
+	clipboard := gtk.BaseWidget(b.parent).Clipboard()
+	clipboard.SetText(b.url)
+}
+
+func (b *baseImage) saveAs() {
+	pixbuf := b.Pixbuf()
+	if pixbuf == nil {
+		return
+	}
+
+	// The context is only used for its values, so it's fine if it gets
+	// cancelled by the time the user picks a file.
+	ctx := b.ctx.Take()
+
+	fileDialog := gtk.NewFileDialog()
+	fileDialog.SetTitle(app.FromContext(ctx).SuffixedTitle(locale.Get("Save Image")))
+	fileDialog.SetInitialName(imgutil.ImageName(b.url) + ".png")
+
+	parent, _ := rootWindow(gtk.BaseWidget(b.parent).Root())
+
+	fileDialog.Save(context.Background(), parent, func(async gio.AsyncResulter) {
+		file, err := fileDialog.SaveFinish(async)
+		if err != nil {
+			return
+		}
+
+		filePath := file.Path()
+		if filePath == "" {
+			app.Error(ctx, fmt.Errorf("failed to save image: no file path"))
+			return
+		}
+
+		go func() {
+			data, err := imgutil.EncodePNG(pixbuf)
+			if err == nil {
+				err = osutil.WriteFile(filePath, data)
+			}
+			if err != nil {
+				glib.IdleAdd(func() {
+					app.Error(ctx, fmt.Errorf("failed to save image: %w", err))
+				})
+			}
+		}()
+	})
+}
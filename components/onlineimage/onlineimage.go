@@ -8,7 +8,6 @@ import (
 
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
 	"github.com/diamondburned/gotk4/pkg/gdk/v4"
-	"github.com/diamondburned/gotk4/pkg/gdkpixbuf/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotkit/gtkutil/imgutil"
 )
@@ -20,16 +19,47 @@ var CanAnimate = true
 type AnimationController baseImage
 
 // Start starts the animation playback in the background. The animation isn't
-// stopped until it is either unmapped or Stop is called.
+// stopped until it is either unmapped or Stop is called. Start does nothing if
+// the animation is paused using Pause.
 func (c *AnimationController) Start() {
 	(*baseImage)(c).startAnimation()
 }
 
-// Stop stops the animation playback.
+// Stop stops the animation playback and rewinds it to its first frame.
 func (c *AnimationController) Stop() {
 	(*baseImage)(c).stopAnimation()
 }
 
+// Pause pauses the animation playback at its current frame. Unlike Stop, the
+// animation stays paused until Resume is called, even if Start is called, such
+// as when the image is hovered over.
+func (c *AnimationController) Pause() {
+	(*baseImage)(c).pauseAnimation()
+}
+
+// Resume resumes the animation playback from where it was paused. If the image
+// is unmapped or its window is unfocused, then nothing is played until Start is
+// called again.
+func (c *AnimationController) Resume() {
+	(*baseImage)(c).resumeAnimation()
+}
+
+// SeekToStart rewinds the animation to its first frame. If the animation is
+// playing, then it keeps playing from there.
+func (c *AnimationController) SeekToStart() {
+	(*baseImage)(c).seekAnimation()
+}
+
+// IsAnimating returns true if the animation is currently playing.
+func (c *AnimationController) IsAnimating() bool {
+	return c.animation != nil && c.animation.animating != 0
+}
+
+// IsPaused returns true if the animation is paused using Pause.
+func (c *AnimationController) IsPaused() bool {
+	return c.animation != nil && c.animation.held
+}
+
 // OnHover binds the controller to a motion controller attached to the image
 // widget. When the user hovers over the image, the animation plays.
 func (c *AnimationController) OnHover() {
@@ -94,18 +124,23 @@ func (a *Avatar) EnableAnimation() *AnimationController {
 	return a.base.enableAnimation()
 }
 
+// EnableLazyLoading makes the avatar only fetch its image once it is scrolled
+// into view within its closest gtk.ScrolledWindow, rather than as soon as it
+// is mapped. Fetches that have not finished are cancelled if the avatar is
+// scrolled away.
+func (a *Avatar) EnableLazyLoading() {
+	a.base.enableLazyLoad()
+}
+
+// EnableContextMenu adds a right-click menu to the avatar with actions to copy
+// the image, copy its URL and save it as a file. The already loaded image is
+// used, so nothing is downloaded again.
+func (a *Avatar) EnableContextMenu() {
+	a.base.enableContextMenu()
+}
+
 func (a *Avatar) set() imgutil.ImageSetter {
-	return imgutil.ImageSetter{
-		SetFromPixbuf: func(pb *gdkpixbuf.Pixbuf) {
-			if pb != nil {
-				texture := gdk.NewTextureForPixbuf(pb)
-				a.Avatar.SetCustomImage(texture)
-			} else {
-				a.Avatar.SetCustomImage(nil)
-			}
-		},
-		SetFromPaintable: a.Avatar.SetCustomImage,
-	}
+	return imgutil.ImageSetterFromAvatar(a.Avatar)
 }
 
 // type avatarParent struct {
@@ -158,11 +193,23 @@ func (i *Image) EnableAnimation() *AnimationController {
 	return i.base.enableAnimation()
 }
 
+// EnableLazyLoading makes the image only fetch its image once it is scrolled
+// into view within its closest gtk.ScrolledWindow, rather than as soon as it
+// is mapped. Fetches that have not finished are cancelled if the image is
+// scrolled away.
+func (i *Image) EnableLazyLoading() {
+	i.base.enableLazyLoad()
+}
+
+// EnableContextMenu adds a right-click menu to the image with actions to copy
+// the image, copy its URL and save it as a file. The already loaded image is
+// used, so nothing is downloaded again.
+func (i *Image) EnableContextMenu() {
+	i.base.enableContextMenu()
+}
+
 func (i *Image) set() imgutil.ImageSetter {
-	return imgutil.ImageSetter{
-		SetFromPixbuf:    i.SetFromPixbuf,
-		SetFromPaintable: i.SetFromPaintable,
-	}
+	return imgutil.ImageSetterFromImage(i.Image)
 }
 
 // Picture is an online variant of gtk.Picture.
@@ -176,6 +223,7 @@ func NewPicture(ctx context.Context, prov imgutil.Provider) *Picture {
 	p := Picture{Picture: gtk.NewPicture()}
 	p.AddCSSClass("onlineimage")
 	p.base.init(ctx, imageParent{&p, &p, p.set()}, prov)
+	p.base.enableStates()
 
 	return &p
 }
@@ -203,9 +251,33 @@ func (p *Picture) EnableAnimation() *AnimationController {
 	return p.base.enableAnimation()
 }
 
+// EnableLazyLoading makes the picture only fetch its image once it is scrolled
+// into view within its closest gtk.ScrolledWindow, rather than as soon as it
+// is mapped. Fetches that have not finished are cancelled if the picture is
+// scrolled away.
+func (p *Picture) EnableLazyLoading() {
+	p.base.enableLazyLoad()
+}
+
+// EnableContextMenu adds a right-click menu to the picture with actions to copy
+// the image, copy its URL and save it as a file. The already loaded image is
+// used, so nothing is downloaded again.
+func (p *Picture) EnableContextMenu() {
+	p.base.enableContextMenu()
+}
+
+// SetPlaceholder sets the paintable shown while the picture is loading. By
+// default, or if placeholder is nil, the picture only shimmers while loading.
+func (p *Picture) SetPlaceholder(placeholder gdk.Paintabler) {
+	p.base.setPlaceholder(placeholder)
+}
+
+// SetErrorIcon sets the name of the icon shown when the picture fails to load.
+// It defaults to DefaultErrorIcon. If name is empty, then nothing is shown.
+func (p *Picture) SetErrorIcon(name string) {
+	p.base.setErrorIcon(name)
+}
+
 func (p *Picture) set() imgutil.ImageSetter {
-	return imgutil.ImageSetter{
-		SetFromPixbuf:    p.SetPixbuf,
-		SetFromPaintable: p.SetPaintable,
-	}
+	return imgutil.ImageSetterFromPicture(p.Picture)
 }
@@ -2,13 +2,31 @@ package onlineimage
 
 import (
 	"log"
+	"sync/atomic"
 
-	"github.com/diamondburned/gotk4/pkg/gdk/v4"
 	"github.com/diamondburned/gotk4/pkg/gdkpixbuf/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/imgutil"
 )
 
+// trimGeneration is incremented every time TrimMemory is called. Each scaler
+// compares it against its own generation to know when to drop its derived
+// pixbufs.
+var trimGeneration atomic.Uint32
+
+func init() { imgutil.OnTrimMemory(TrimMemory) }
+
+// TrimMemory drops the pixbufs kept by onlineimage widgets. Rescaled pixbufs
+// are dropped as each widget is rescaled, while the full-size ones are dropped
+// as each widget is unmapped and fetched again, usually from the disk cache,
+// once it is mapped again. It is automatically called by imgutil.TrimMemory.
+//
+// This function can be called from any thread.
+func TrimMemory() {
+	trimGeneration.Add(1)
+}
+
 type pixbufScaler struct {
 	parent *baseImage
 	// parentSz keeps track of the parent widget's sizes in case it has been
@@ -18,6 +36,8 @@ type pixbufScaler struct {
 	src *gdkpixbuf.Pixbuf
 	// src1x is the source pixbuf at 1x scale.
 	src1x *gdkpixbuf.Pixbuf
+	// trimGen is the trimGeneration that src and src1x were made in.
+	trimGen uint32
 }
 
 // SetFromPixbuf invalidates and sets the internal scaler's pixbuf. The
@@ -49,22 +69,44 @@ func (p *pixbufScaler) init(parent *baseImage) {
 	base.ConnectMap(func() {
 		p.Invalidate()
 	})
+	base.ConnectUnmap(func() {
+		p.release()
+	})
 	base.NotifyProperty("scale-factor", func() {
 		gtkutil.SetScaleFactor(parent.scale())
 		p.Invalidate()
 	})
 
-	// Call Invalidate for 5 ticks, which seems to be enough to trick GTK into
-	// giving us the correct scale factor. The actual fix would probably involve
-	// connecting to various different signals, but this is good enough for now.
-	var ticks int
-	base.AddTickCallback(func(gtk.Widgetter, gdk.FrameClocker) bool {
-		p.Invalidate()
-		ticks++
-		return ticks < 5 && p.parent.scale() != gtkutil.ScaleFactor()
+	gtkutil.BindSubscribe(base, func() func() {
+		return gtkutil.OnScaleFactorChanged(func(int) { p.Invalidate() })
 	})
 }
 
+// trim drops src1x if TrimMemory has been called since it was made.
+func (p *pixbufScaler) trim() {
+	if gen := trimGeneration.Load(); gen != p.trimGen {
+		p.src1x = nil
+		p.trimGen = gen
+	}
+}
+
+// release drops both src and src1x if TrimMemory has been called since they
+// were made. It must only be called while the widget is unmapped.
+func (p *pixbufScaler) release() {
+	gen := trimGeneration.Load()
+	if gen == p.trimGen {
+		return
+	}
+
+	p.trimGen = gen
+	p.src1x = nil
+
+	if p.src != nil {
+		p.src = nil
+		p.parent.release()
+	}
+}
+
 func (p *pixbufScaler) setParentPixbuf(pixbuf *gdkpixbuf.Pixbuf) {
 	setter := p.parent.setter
 	setter.SetFromPixbuf(pixbuf)
@@ -74,6 +116,8 @@ func (p *pixbufScaler) setParentPixbuf(pixbuf *gdkpixbuf.Pixbuf) {
 // needed. The user should use this method instead of calling on the parent
 // widget's Refetch method.
 func (p *pixbufScaler) invalidate() {
+	p.trim()
+
 	if p.src == nil {
 		return
 	}
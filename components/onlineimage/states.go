@@ -0,0 +1,133 @@
+package onlineimage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+)
+
+// DefaultErrorIcon is the icon shown by images that failed to load.
+const DefaultErrorIcon = "image-missing-symbolic"
+
+// defaultErrorIconSize is the size of the error icon if the image has no size
+// request.
+const defaultErrorIconSize = 32
+
+var _ = cssutil.WriteCSS(`
+	@keyframes onlineimage-shimmer {
+		from { opacity: 0.35; }
+		to   { opacity: 0.85; }
+	}
+	.onlineimage-loading {
+		background-color: alpha(currentColor, 0.1);
+		animation: onlineimage-shimmer 0.8s ease-in-out infinite alternate;
+	}
+	.onlineimage-error {
+		color: alpha(currentColor, 0.5);
+	}
+`)
+
+type loadState uint8
+
+const (
+	loadIdle loadState = iota
+	loadLoading
+	loadDone
+	loadFailed
+)
+
+// loadStates holds the loading and error states of an image. It is only
+// used if the image has enabled them.
+type loadStates struct {
+	placeholder gdk.Paintabler
+	errorIcon   string
+	state       loadState
+}
+
+func (b *baseImage) enableStates() {
+	b.states = &loadStates{errorIcon: DefaultErrorIcon}
+}
+
+// setPlaceholder sets the paintable shown while the image is loading. If it's
+// nil, then only the shimmer is shown.
+func (b *baseImage) setPlaceholder(placeholder gdk.Paintabler) {
+	b.states.placeholder = placeholder
+	if b.states.state == loadLoading {
+		b.setState(loadLoading)
+	}
+}
+
+// setErrorIcon sets the icon shown when the image fails to load. If it's
+// empty, then nothing is shown.
+func (b *baseImage) setErrorIcon(name string) {
+	b.states.errorIcon = name
+	if b.states.state == loadFailed {
+		b.setState(loadFailed)
+	}
+}
+
+// stateCtx returns a context that transitions the image into the failed state
+// if loading the given URL fails.
+func (b *baseImage) stateCtx(ctx context.Context, url string) context.Context {
+	if b.states == nil {
+		return ctx
+	}
+
+	return imgutil.WithOpts(ctx, imgutil.WithChainedDoneFn(func(err error) {
+		if err == nil || errors.Is(err, context.Canceled) || b.url != url || b.ok {
+			return
+		}
+		b.setState(loadFailed)
+	}))
+}
+
+func (b *baseImage) setState(state loadState) {
+	if b.states == nil {
+		return
+	}
+
+	prev := b.states.state
+	b.states.state = state
+
+	base := gtk.BaseWidget(b.parent)
+	base.RemoveCSSClass("onlineimage-loading")
+	base.RemoveCSSClass("onlineimage-error")
+
+	switch state {
+	case loadIdle:
+		if prev == loadLoading || prev == loadFailed {
+			b.setter.SetFromPaintable(nil)
+		}
+	case loadLoading:
+		base.AddCSSClass("onlineimage-loading")
+		b.setter.SetFromPaintable(b.states.placeholder)
+	case loadFailed:
+		base.AddCSSClass("onlineimage-error")
+		b.setter.SetFromPaintable(b.errorPaintable())
+	}
+}
+
+func (b *baseImage) errorPaintable() gdk.Paintabler {
+	if b.states.errorIcon == "" {
+		return nil
+	}
+
+	w, h := b.sizeRequest()
+	size := min(w, h)
+	if size < 1 {
+		size = max(w, h)
+	}
+	if size < 1 {
+		size = defaultErrorIconSize
+	}
+
+	icon := imgutil.IconPaintable(b.states.errorIcon, size, size)
+	if icon == nil {
+		return nil
+	}
+	return icon
+}
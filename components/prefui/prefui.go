@@ -1,15 +1,19 @@
 package prefui
 
 import (
+	"bytes"
 	"context"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotk4/pkg/pango"
 	"github.com/diamondburned/gotkit/app"
 	"github.com/diamondburned/gotkit/app/locale"
 	"github.com/diamondburned/gotkit/app/prefs"
+	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
 	"github.com/diamondburned/gotkit/utils/config"
 	"github.com/pkg/errors"
@@ -26,9 +30,21 @@ type Dialog struct {
 	loading  *gtk.Spinner
 	sections []*section
 
-	saver config.ConfigStore
+	saver   config.ConfigStore
+	journal *prefs.Journal
+
+	// apply and applied are only used in buffered mode. applied is the
+	// snapshot of the preferences as of the last time they were applied.
+	apply   *gtk.Button
+	applied prefs.Snapshot
 }
 
+// Buffered makes the preferences dialog not save any change until the Apply
+// button is clicked. Closing the dialog with changes that are not applied asks
+// the user whether to apply or discard them, and discarded changes are
+// reverted. It must be set before the dialog is shown.
+var Buffered bool
+
 var currentDialog *Dialog
 
 // ShowDialog shows the preferences dialog.
@@ -40,11 +56,32 @@ func ShowDialog(ctx context.Context) {
 	}
 
 	dialog := newDialog(ctx)
-	dialog.ConnectClose(func() {
+	dialog.ConnectCloseRequest(func() bool {
 		currentDialog = nil
-		dialog.Destroy()
+		dialog.journal.Close()
+		dialog.saver.Close()
+		return false
 	})
+	if win := app.GTKWindowFromContext(ctx); win != nil {
+		gtkutil.RestoreFocusOnClose(dialog, win)
+	}
 	dialog.Show()
+
+	currentDialog = dialog
+	shutdownOnce.Do(func() {
+		app.FromContext(ctx).OnShutdown(FlushDialog)
+	})
+}
+
+var shutdownOnce sync.Once
+
+// FlushDialog synchronously saves any pending change made in the currently
+// open preferences dialog, if any. It is automatically called when the
+// application shuts down.
+func FlushDialog() {
+	if currentDialog != nil {
+		currentDialog.saver.Flush()
+	}
 }
 
 var _ = cssutil.WriteCSS(`
@@ -62,7 +99,7 @@ var _ = cssutil.WriteCSS(`
 		color: mix(@theme_fg_color, @theme_bg_color, 0.15);
 	}
 
-	row.prefui-prop, list.prefui-section {
+	row.prefui-prop, row.prefui-group, list.prefui-section {
 		border: none;
 		background: none;
 	}
@@ -89,6 +126,10 @@ var _ = cssutil.WriteCSS(`
 	.prefui-prop > box.horizontal > .prefui-prop {
 		margin-left: 6px;
 	}
+	.prefui-prop > box.horizontal > .prefui-prop:dir(rtl) {
+		margin-left: 0;
+		margin-right: 6px;
+	}
 
 	.prefui-prop-description {
 		font-size: 0.9em;
@@ -98,15 +139,36 @@ var _ = cssutil.WriteCSS(`
 	.prefui-prop-string {
 		font-size: 0.9em;
 	}
+
+	.prefui-prop-dirty .prefui-prop-name {
+		font-style: italic;
+	}
+
+	.prefui-section > row.prefui-group {
+		margin-bottom: 0;
+	}
+
+	.prefui-group-name {
+		font-weight: bold;
+	}
+
+	.prefui-section > row.prefui-prop-grouped {
+		margin-left: 22px;
+	}
+	.prefui-section > row.prefui-prop-grouped:dir(rtl) {
+		margin-left: 0;
+		margin-right: 22px;
+	}
 `)
 
-func configSnapshotter(ctx context.Context) func() (save func()) {
-	return func() func() {
+func configSnapshotter(ctx context.Context) func() (save func() error) {
+	return func() func() error {
 		snapshot := prefs.TakeSnapshot()
-		return func() {
+		return func() error {
 			if err := snapshot.Save(ctx); err != nil {
-				app.Error(ctx, errors.Wrap(err, "cannot save prefs"))
+				return errors.Wrap(err, "cannot save prefs")
 			}
+			return nil
 		}
 	}
 }
@@ -115,8 +177,9 @@ func configSnapshotter(ctx context.Context) func() (save func()) {
 func newDialog(ctx context.Context) *Dialog {
 	d := Dialog{ctx: ctx}
 
-	d.saver = config.NewConfigStore(configSnapshotter(ctx))
+	d.saver = config.NewConfigStoreWithError(configSnapshotter(ctx))
 	d.saver.Widget = (*dialogSaver)(&d)
+	d.saver.OnError = func(err error) { app.Error(d.ctx, err) }
 	// Computers are just way too fast. Ensure that the loading circle visibly
 	// pops up before it closes.
 	d.saver.Minimum = 100 * time.Millisecond
@@ -166,7 +229,7 @@ func newDialog(ctx context.Context) *Dialog {
 	d.Dialog.SetDefaultSize(400, 500)
 	d.Dialog.SetChild(outerBox)
 
-	if app.IsDevel() {
+	if app.FromContext(ctx).IsDevel() {
 		d.Dialog.AddCSSClass("devel")
 	}
 
@@ -181,9 +244,53 @@ func newDialog(ctx context.Context) *Dialog {
 	d.header.PackEnd(searchButton)
 	d.header.PackEnd(d.loading)
 
+	if Buffered {
+		d.applied = prefs.TakeSnapshot()
+
+		d.apply = gtk.NewButtonWithLabel(locale.Get("Apply"))
+		d.apply.AddCSSClass("suggested-action")
+		d.apply.SetSensitive(false)
+		d.apply.ConnectClicked(d.Apply)
+		d.header.PackStart(d.apply)
+	}
+
+	d.journal = prefs.NewJournal(0)
+	gtkutil.BindCloseGuarded(d.Dialog, d.confirmClose)
+	gtkutil.BindKeys(d.Dialog, map[string]func() bool{
+		"<Ctrl>z":        d.Undo,
+		"<Ctrl><Shift>z": d.Redo,
+		"<Ctrl>y":        d.Redo,
+	})
+
 	return &d
 }
 
+// Undo undoes the last change made to the preferences while the dialog is
+// open. False is returned if nothing is undone, such as when a text field is
+// focused, so that it can undo its own text instead.
+func (d *Dialog) Undo() bool {
+	if d.editing() || !d.journal.Undo() {
+		return false
+	}
+	d.save()
+	return true
+}
+
+// Redo redoes the last change undone using Undo.
+func (d *Dialog) Redo() bool {
+	if d.editing() || !d.journal.Redo() {
+		return false
+	}
+	d.save()
+	return true
+}
+
+// editing returns true if a text field is focused.
+func (d *Dialog) editing() bool {
+	_, ok := d.Dialog.Focus().(*gtk.Text)
+	return ok
+}
+
 func (d *Dialog) Search(query string) {
 	query = strings.ToLower(query)
 	for _, section := range d.sections {
@@ -192,9 +299,103 @@ func (d *Dialog) Search(query string) {
 }
 
 func (d *Dialog) save() {
+	if d.applied != nil {
+		d.updateDirty()
+		return
+	}
 	d.saver.Save()
 }
 
+// Dirty returns true if the dialog is in buffered mode and has changes that
+// are not applied yet.
+func (d *Dialog) Dirty() bool {
+	return d.apply != nil && d.apply.Sensitive()
+}
+
+// Apply saves all changes made in buffered mode. It does nothing if the dialog
+// is not in buffered mode.
+func (d *Dialog) Apply() {
+	if d.applied == nil {
+		return
+	}
+	d.applied = prefs.TakeSnapshot()
+	d.saver.Save()
+	d.updateDirty()
+}
+
+// Discard reverts all changes made in buffered mode that are not applied yet.
+// It does nothing if the dialog is not in buffered mode.
+func (d *Dialog) Discard() {
+	if d.applied == nil {
+		return
+	}
+	if err := prefs.LoadData(d.applied.JSON()); err != nil {
+		app.Error(d.ctx, errors.Wrap(err, "cannot revert prefs"))
+	}
+	d.updateDirty()
+}
+
+// updateDirty marks the properties that are changed since the last time they
+// were applied.
+func (d *Dialog) updateDirty() {
+	var dirty bool
+	for _, section := range d.sections {
+		for _, row := range section.props {
+			if d.propDirty(row.prop) {
+				row.AddCSSClass("prefui-prop-dirty")
+				dirty = true
+			} else {
+				row.RemoveCSSClass("prefui-prop-dirty")
+			}
+		}
+	}
+	d.apply.SetSensitive(dirty)
+}
+
+func (d *Dialog) propDirty(prop prefs.Prop) bool {
+	b, err := prop.MarshalJSON()
+	if err != nil {
+		return false
+	}
+	return !bytes.Equal(b, d.applied[string(prop.Meta().ID())])
+}
+
+// confirmClose asks the user whether to apply or discard the changes that are
+// not applied yet before closing the dialog. It returns true if the dialog can
+// be closed right away.
+func (d *Dialog) confirmClose() bool {
+	if !d.Dirty() {
+		return true
+	}
+
+	confirm := adw.NewMessageDialog(
+		&d.Dialog.Window,
+		locale.Get("Apply Changes?"),
+		locale.Get("Some preferences were changed but not applied yet."),
+	)
+	confirm.AddResponse("cancel", locale.Get("Cancel"))
+	confirm.AddResponse("discard", locale.Get("Discard"))
+	confirm.AddResponse("apply", locale.Get("Apply"))
+	confirm.SetResponseAppearance("discard", adw.ResponseDestructive)
+	confirm.SetResponseAppearance("apply", adw.ResponseSuggested)
+	confirm.SetDefaultResponse("apply")
+	confirm.SetCloseResponse("cancel")
+	confirm.ConnectResponse(func(response string) {
+		switch response {
+		case "apply":
+			d.Apply()
+		case "discard":
+			d.Discard()
+		default:
+			return
+		}
+		d.Dialog.Close()
+	})
+	confirm.Present()
+
+	return false
+}
+
 type dialogSaver Dialog
 
 func (d *dialogSaver) SaveBegin() {
@@ -203,6 +404,12 @@ func (d *dialogSaver) SaveBegin() {
 }
 
 func (d *dialogSaver) SaveEnd() {
+	// Saving may finish after the dialog is closed, by which point its widgets
+	// are gone.
+	if currentDialog != (*Dialog)(d) {
+		return
+	}
+
 	d.loading.Stop()
 	d.loading.Hide()
 }
@@ -213,6 +420,8 @@ type section struct {
 	list *gtk.ListBox
 
 	props []*propRow
+	// terms holds the search term of each row in the list.
+	terms []string
 
 	searching string
 	noResults bool
@@ -223,12 +432,26 @@ func newSection(d *Dialog, sect prefs.ListedSection) *section {
 	s.list = gtk.NewListBox()
 	s.list.AddCSSClass("prefui-section")
 	s.list.SetSelectionMode(gtk.SelectionNone)
-	s.list.SetActivateOnSingleClick(true)
+	gtkutil.SingleClickActivate(s.list, s.activate)
 
 	s.props = make([]*propRow, len(sect.Props))
+	var group *groupRow
 	for i, prop := range sect.Props {
+		if prop.Group != nil && (group == nil || group.group != prop.Group) {
+			group = newGroupRow(prop.Group)
+			s.list.Append(group)
+			s.terms = append(s.terms, group.queryTerm)
+		}
+
 		s.props[i] = newPropRow(d, prop)
 		s.list.Append(s.props[i])
+		s.terms = append(s.terms, s.props[i].queryTerm)
+
+		if prop.Group != nil {
+			// Show the group if any of its props match.
+			s.terms[len(s.terms)-1] += "\n" + group.queryTerm
+			s.terms[group.Index()] += "\n" + s.props[i].queryTerm
+		}
 	}
 
 	s.name = gtk.NewLabel(sect.Name)
@@ -241,9 +464,7 @@ func newSection(d *Dialog, sect prefs.ListedSection) *section {
 	s.Box.Append(s.list)
 
 	s.list.SetFilterFunc(func(row *gtk.ListBoxRow) bool {
-		prop := s.props[row.Index()]
-
-		if strings.Contains(prop.queryTerm, s.searching) {
+		if strings.Contains(s.terms[row.Index()], s.searching) {
 			s.noResults = false
 			return true
 		}
@@ -254,6 +475,17 @@ func newSection(d *Dialog, sect prefs.ListedSection) *section {
 	return &s
 }
 
+// activate activates the property of the given row, such as toggling a
+// switch.
+func (s *section) activate(row *gtk.ListBoxRow) {
+	for _, prop := range s.props {
+		if prop.Index() == row.Index() {
+			prop.Activate()
+			return
+		}
+	}
+}
+
 func (s *section) Search(query string) {
 	s.noResults = true
 	s.searching = query
@@ -262,9 +494,56 @@ func (s *section) Search(query string) {
 	s.SetVisible(!s.noResults)
 }
 
+// queryTerm returns the search term for the given strings. They are joined
+// with new lines, which a search query can't contain, so that a query never
+// matches across two of them.
+func queryTerm(strs ...string) string {
+	return strings.ToLower(strings.Join(strs, "\n"))
+}
+
+type groupRow struct {
+	*gtk.ListBoxRow
+	group *prefs.LocalizedGroup
+
+	queryTerm string
+}
+
+func newGroupRow(group *prefs.LocalizedGroup) *groupRow {
+	row := groupRow{
+		group:     group,
+		queryTerm: queryTerm(group.Name, group.Description),
+	}
+
+	box := gtk.NewBox(gtk.OrientationVertical, 0)
+
+	name := gtk.NewLabel(group.Name)
+	name.AddCSSClass("prefui-group-name")
+	name.SetXAlign(0)
+	name.SetWrap(true)
+	name.SetWrapMode(pango.WrapWordChar)
+	box.Append(name)
+
+	if group.Description != "" {
+		desc := gtk.NewLabel(group.Description)
+		desc.AddCSSClass("prefui-prop-description")
+		desc.SetXAlign(0)
+		desc.SetWrap(true)
+		desc.SetWrapMode(pango.WrapWordChar)
+		box.Append(desc)
+	}
+
+	row.ListBoxRow = gtk.NewListBoxRow()
+	row.AddCSSClass("prefui-group")
+	row.SetActivatable(false)
+	row.SetChild(box)
+
+	return &row
+}
+
 type propRow struct {
 	*gtk.ListBoxRow
-	box *gtk.Box
+	box  *gtk.Box
+	prop prefs.Prop
 
 	left struct {
 		*gtk.Box
@@ -283,8 +562,9 @@ type propWidget struct {
 
 func newPropRow(d *Dialog, prop prefs.LocalizedProp) *propRow {
 	row := propRow{
+		prop: prop.Prop,
 		// Hacky way to do case-insensitive search.
-		queryTerm: strings.ToLower(prop.Name) + strings.ToLower(prop.Description),
+		queryTerm: queryTerm(prop.Name, prop.Description),
 	}
 
 	row.ListBoxRow = gtk.NewListBoxRow()
@@ -329,6 +609,17 @@ func newPropRow(d *Dialog, prop prefs.LocalizedProp) *propRow {
 
 	row.SetChild(row.box)
 
+	if group := prop.Group; group != nil {
+		row.AddCSSClass("prefui-prop-grouped")
+
+		// The rest of the group can only be changed when the toggle is on.
+		if group.Toggle != nil && prop.Prop != prefs.Prop(group.Toggle) {
+			group.Toggle.SubscribeWidget(row, func() {
+				row.SetSensitive(group.Enabled())
+			})
+		}
+	}
+
 	return &row
 }
 
@@ -0,0 +1,128 @@
+package gtkutil
+
+import (
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+)
+
+// AccessibilityChecks is true if CheckAccessibility should do anything. It
+// defaults to true if the GOTKIT_A11Y_CHECK environment variable is set. It
+// must be set before any widget is created.
+var AccessibilityChecks = os.Getenv("GOTKIT_A11Y_CHECK") != ""
+
+// labeledWidgets keeps track of the widgets that were labeled using
+// SetAccessible, since GTK does not allow reading back accessible properties.
+// It is only populated if AccessibilityChecks is true, and widgets are removed
+// from it once they're destroyed.
+var labeledWidgets sync.Map // uintptr -> struct{}
+
+// SetAccessible sets the accessible label and description of w, which are read
+// by screen readers. Empty strings are ignored.
+//
+// Note that the accessible role of a widget can only be set during its
+// construction in GTK4, so it is not settable here.
+func SetAccessible(w gtk.Widgetter, label, description string) {
+	var props []gtk.AccessibleProperty
+	var values []coreglib.Value
+
+	if label != "" {
+		props = append(props, gtk.AccessiblePropertyLabel)
+		values = append(values, *coreglib.NewValue(label))
+	}
+
+	if description != "" {
+		props = append(props, gtk.AccessiblePropertyDescription)
+		values = append(values, *coreglib.NewValue(description))
+	}
+
+	if len(props) == 0 {
+		return
+	}
+
+	widget := gtk.BaseWidget(w)
+	widget.UpdateProperty(props, values)
+
+	if AccessibilityChecks && label != "" {
+		ptr := widget.Native()
+		if _, loaded := labeledWidgets.LoadOrStore(ptr, struct{}{}); !loaded {
+			// Forget the widget once it's gone, since its address may be
+			// reused by another widget.
+			widget.ConnectDestroy(func() { labeledWidgets.Delete(ptr) })
+		}
+	}
+}
+
+// interactiveRoles is the set of accessible roles that must have a label.
+var interactiveRoles = map[gtk.AccessibleRole]struct{}{
+	gtk.AccessibleRoleButton:           {},
+	gtk.AccessibleRoleCheckbox:         {},
+	gtk.AccessibleRoleComboBox:         {},
+	gtk.AccessibleRoleLink:             {},
+	gtk.AccessibleRoleMenuItem:         {},
+	gtk.AccessibleRoleMenuItemCheckbox: {},
+	gtk.AccessibleRoleMenuItemRadio:    {},
+	gtk.AccessibleRoleRadio:            {},
+	gtk.AccessibleRoleSearchBox:        {},
+	gtk.AccessibleRoleSlider:           {},
+	gtk.AccessibleRoleSpinButton:       {},
+	gtk.AccessibleRoleSwitch:           {},
+	gtk.AccessibleRoleTextBox:          {},
+	gtk.AccessibleRoleToggleButton:     {},
+}
+
+// CheckAccessibility walks w and logs a warning for every interactive widget
+// that does not seem to have a label. A widget is considered labeled if it
+// was labeled using SetAccessible, has a tooltip or contains a non-empty
+// gtk.Label. It is meant for debugging, so it does nothing unless
+// AccessibilityChecks is true.
+func CheckAccessibility(w gtk.Widgetter) {
+	if !AccessibilityChecks {
+		return
+	}
+
+	WalkWidget(w, func(w gtk.Widgetter) bool {
+		widget := gtk.BaseWidget(w)
+
+		if _, ok := interactiveRoles[widget.AccessibleRole()]; !ok {
+			return false
+		}
+
+		if !isLabeled(widget) {
+			slog.Warn(
+				"interactive widget has no accessible label",
+				"module", "gtkutil",
+				"type", widget.Type().Name(),
+				"name", widget.Name(),
+				"css_classes", widget.CSSClasses())
+		}
+
+		// Don't check the children of interactive widgets, since they're
+		// usually part of the widget itself.
+		return true
+	})
+}
+
+func isLabeled(widget *gtk.Widget) bool {
+	if _, ok := labeledWidgets.Load(widget.Native()); ok {
+		return true
+	}
+
+	if widget.TooltipText() != "" {
+		return true
+	}
+
+	var labeled bool
+	WalkWidget(widget, func(w gtk.Widgetter) bool {
+		if label, ok := w.(*gtk.Label); ok && label.Text() != "" {
+			labeled = true
+		}
+		return labeled
+	})
+
+	return labeled
+}
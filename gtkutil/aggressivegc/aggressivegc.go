@@ -3,19 +3,92 @@
 //
 // Use it like so:
 //
-//    import _ "github.com/diamondburned/gotkit/gtkutil/aggressivegc"
+//	import _ "github.com/diamondburned/gotkit/gtkutil/aggressivegc"
 //
+// The interval can be changed using SetInterval, and the GC loop can be stopped
+// using Stop.
 package aggressivegc
 
 import (
 	"runtime"
+	"sync"
 	"time"
 )
 
-func init() {
-	go func() {
-		for range time.Tick(time.Minute) {
+// DefaultInterval is the default interval between each forced GC.
+const DefaultInterval = time.Minute
+
+var (
+	mu       sync.Mutex
+	interval = DefaultInterval
+	stop     chan struct{} // nil if not running
+)
+
+func init() { Start() }
+
+// Start starts the GC loop if it's not already running. It is called
+// automatically when the package is imported, so it only needs to be called
+// after Stop.
+func Start() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	start()
+}
+
+func start() {
+	if stop != nil {
+		return
+	}
+
+	stop = make(chan struct{})
+	go loop(interval, stop)
+}
+
+// Stop stops the GC loop. It does nothing if the loop is not running.
+func Stop() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	halt()
+}
+
+func halt() {
+	if stop != nil {
+		close(stop)
+		stop = nil
+	}
+}
+
+// SetInterval sets the interval between each forced GC. If the GC loop is
+// running, then it is restarted with the new interval. If d is zero or
+// negative, then DefaultInterval is used.
+func SetInterval(d time.Duration) {
+	if d <= 0 {
+		d = DefaultInterval
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	interval = d
+
+	if stop != nil {
+		halt()
+		start()
+	}
+}
+
+func loop(d time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
 			runtime.GC()
+		case <-stop:
+			return
 		}
-	}()
+	}
 }
@@ -3,6 +3,7 @@ package gtkutil
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/diamondburned/gotk4/pkg/core/glib"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
@@ -11,10 +12,16 @@ import (
 // IdleCtx runs the given callback inside the main loop only if the context has
 // not expired.
 func IdleCtx(ctx context.Context, f func()) {
-	glib.IdleAdd(func() {
+	IdleAddContext(ctx, f)
+}
+
+// IdleAddContext schedules f to be run in the main loop. If ctx is cancelled by
+// the time f would run, then f is skipped. The returned handle can be given to
+// glib.SourceRemove to unschedule f explicitly.
+func IdleAddContext(ctx context.Context, f func()) glib.SourceHandle {
+	return glib.IdleAdd(func() {
 		select {
 		case <-ctx.Done():
-
 		default:
 			f()
 		}
@@ -155,7 +162,13 @@ func (r *renewFns) remove(k *funcKey) {
 }
 
 // WithVisibility creates a new context that is canceled when the widget is
-// hidden.
+// hidden. The context is cancelled right away if the widget is not yet mapped,
+// and it is renewed every time the widget is mapped again, which is when the
+// functions added using OnRenew are called.
+//
+// This is useful for tying background work such as image fetching to the
+// widget: work is stopped once the widget is no longer shown, and it can be
+// restarted in OnRenew once it is.
 func WithVisibility(ctx context.Context, widget gtk.Widgetter) Canceller {
 	c := WithCanceller(ctx)
 	w := gtk.BaseWidget(widget)
@@ -167,6 +180,44 @@ func WithVisibility(ctx context.Context, widget gtk.Widgetter) Canceller {
 	return c
 }
 
+// WithVisibilityGrace is like WithVisibility, except the context is only
+// cancelled once the widget has stayed unmapped for longer than grace. If the
+// widget is mapped again within the grace period, then the context is kept
+// alive and OnRenew is not called.
+//
+// This is useful for widgets inside lists, which are unmapped and mapped often
+// while scrolling. Work for widgets that are only briefly hidden is kept going,
+// while work for widgets scrolled far away is stopped and restarted in OnRenew
+// once they are shown again.
+func WithVisibilityGrace(ctx context.Context, widget gtk.Widgetter, grace time.Duration) Canceller {
+	c := WithCanceller(ctx)
+	w := gtk.BaseWidget(widget)
+	if !w.Mapped() {
+		c.Cancel()
+	}
+
+	var timeout glib.SourceHandle
+	w.ConnectMap(func() {
+		if timeout != 0 {
+			glib.SourceRemove(timeout)
+			timeout = 0
+		}
+		// Renew does nothing if the context was never cancelled.
+		c.Renew()
+	})
+	w.ConnectUnmap(func() {
+		if timeout != 0 {
+			return
+		}
+		timeout = glib.TimeoutAdd(uint(grace.Milliseconds()), func() {
+			timeout = 0
+			c.Cancel()
+		})
+	})
+
+	return c
+}
+
 // WithCanceller wraps around a context.
 func WithCanceller(ctx context.Context) Canceller {
 	old := ctx
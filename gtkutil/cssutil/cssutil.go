@@ -8,7 +8,9 @@ import (
 	"strings"
 	"text/template"
 
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
 	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 )
 
@@ -18,6 +20,8 @@ var globalCSS = func() *strings.Builder {
 	return &b
 }()
 
+var highContrastCSS strings.Builder
+
 var globalVariables = template.FuncMap{}
 
 // AddCSSVariables adds the variables from the given map into the global
@@ -34,6 +38,21 @@ func AddCSSVariables(vars map[string]string) {
 	}
 }
 
+// AddDefaultCSSVariables is like AddCSSVariables, except variables that
+// already exist are not overridden. Libraries should use this to declare the
+// default values of their variables, so that applications can override them
+// regardless of initialization order.
+func AddDefaultCSSVariables(vars map[string]string) {
+	for k, v := range vars {
+		k := k
+		v := v
+
+		if _, ok := globalVariables[k]; !ok {
+			globalVariables[k] = func() string { return v }
+		}
+	}
+}
+
 func templateCSS(name, css string) string {
 	var err error
 
@@ -87,6 +106,21 @@ func WriteCSS(css string) struct{} {
 	return struct{}{}
 }
 
+// WriteHighContrastCSS is like WriteCSS, except the given CSS is only applied
+// while the desktop requests high contrast. It is meant for overriding colors
+// that would otherwise fail contrast requirements. Like WriteCSS, it must be
+// called before ApplyGlobalCSS.
+func WriteHighContrastCSS(css string) struct{} {
+	highContrastCSS.WriteString(css)
+	return struct{}{}
+}
+
+// IsHighContrast returns true if the desktop currently requests high contrast.
+// It must be called on the main thread.
+func IsHighContrast() bool {
+	return adw.StyleManagerGetDefault().HighContrast()
+}
+
 // AddClass adds classes.
 func AddClass(w gtk.Widgetter, classes ...string) {
 	ctx := gtk.BaseWidget(w).StyleContext()
@@ -95,12 +129,86 @@ func AddClass(w gtk.Widgetter, classes ...string) {
 	}
 }
 
-// ApplyGlobalCSS applies the current global CSS to the default display.
+// BindThemeClasses adds darkClass to w while the application is dark and
+// lightClass otherwise, keeping them in sync with theme changes while w is
+// mapped. Either class may be empty.
+//
+// This is the class-toggling counterpart of gtkutil.OnThemeChange, which
+// cssutil can't use since gtkutil imports cssutil.
+func BindThemeClasses(w gtk.Widgetter, darkClass, lightClass string) {
+	widget := gtk.BaseWidget(w)
+	styles := adw.StyleManagerGetDefault()
+
+	update := func() {
+		add, remove := lightClass, darkClass
+		if styles.Dark() {
+			add, remove = darkClass, lightClass
+		}
+		if remove != "" {
+			widget.RemoveCSSClass(remove)
+		}
+		if add != "" {
+			widget.AddCSSClass(add)
+		}
+	}
+
+	var handle glib.SignalHandle
+	subscribe := func() {
+		update()
+		handle = styles.NotifyProperty("dark", update)
+	}
+
+	if widget.Mapped() {
+		subscribe()
+	}
+	widget.ConnectMap(subscribe)
+	widget.ConnectUnmap(func() {
+		if handle != 0 {
+			styles.HandlerDisconnect(handle)
+			handle = 0
+		}
+	})
+}
+
+// ApplyGlobalCSS applies the current global CSS to the default display. The
+// CSS written using WriteHighContrastCSS is applied on top of it whenever the
+// desktop requests high contrast.
 func ApplyGlobalCSS() {
 	globalCSS := templateCSS("global", globalCSS.String())
 	prov := newCSSProvider("<global>", globalCSS)
 	display := gdk.DisplayGetDefault()
 	gtk.StyleContextAddProviderForDisplay(display, prov, gtk.STYLE_PROVIDER_PRIORITY_APPLICATION)
+
+	if highContrastCSS.Len() > 0 {
+		applyHighContrastCSS(display)
+	}
+}
+
+func applyHighContrastCSS(display *gdk.Display) {
+	css := templateCSS("high-contrast", highContrastCSS.String())
+	prov := newCSSProvider("<high-contrast>", css)
+
+	var applied bool
+	update := func() {
+		highContrast := IsHighContrast()
+		if highContrast == applied {
+			return
+		}
+
+		if highContrast {
+			// Use a slightly higher priority so that it always overrides the
+			// global CSS.
+			gtk.StyleContextAddProviderForDisplay(display, prov, gtk.STYLE_PROVIDER_PRIORITY_APPLICATION+1)
+		} else {
+			gtk.StyleContextRemoveProviderForDisplay(display, prov)
+		}
+
+		applied = highContrast
+	}
+
+	styles := adw.StyleManagerGetDefault()
+	styles.NotifyProperty("high-contrast", update)
+	update()
 }
 
 // ApplyUserCSS applies the user CSS at the given path.
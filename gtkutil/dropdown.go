@@ -0,0 +1,205 @@
+package gtkutil
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/diamondburned/gotk4/pkg/core/glib"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+)
+
+// typeAheadTimeout is the time after the last key press that type-ahead in a
+// dropdown starts over.
+const typeAheadTimeout = time.Second
+
+// NewSearchableDropDown creates a new gtk.DropDown of the given items. The
+// dropdown's popover has a search entry that filters the items, and typing
+// while the dropdown is focused selects the first item that starts with the
+// typed text. onSelected is called with the index of the item that is
+// selected, including when it's selected using SetSelected; it may be nil.
+func NewSearchableDropDown(items []string, onSelected func(int)) *gtk.DropDown {
+	return newSearchableDropDown(items, nil, onSelected)
+}
+
+// DropDownDisabledFunc returns whether the item at index i is disabled, along
+// with the reason that is shown to the user. The reason may be empty.
+type DropDownDisabledFunc func(i int) (reason string, disabled bool)
+
+// NewSearchableDropDownDisabled is like NewSearchableDropDown, except items for
+// which disabled returns true are greyed out and cannot be selected, with the
+// reason shown as their tooltip. Selecting a disabled item, e.g. using
+// SetSelected, reverts the selection without calling onSelected. disabled is
+// called whenever an item is shown or selected, so its result may change over
+// time, but items that are already shown aren't updated.
+func NewSearchableDropDownDisabled(items []string, disabled DropDownDisabledFunc, onSelected func(int)) *gtk.DropDown {
+	return newSearchableDropDown(items, disabled, onSelected)
+}
+
+// NewSearchableDropDownFunc is like NewSearchableDropDown, except the items
+// can be of any type, and display is used to get the text shown for each
+// item. The text is also used for searching.
+func NewSearchableDropDownFunc[T any](items []T, display func(T) string, onSelected func(T)) *gtk.DropDown {
+	labels := make([]string, len(items))
+	for i, item := range items {
+		labels[i] = display(item)
+	}
+
+	var selected func(int)
+	if onSelected != nil {
+		selected = func(i int) { onSelected(items[i]) }
+	}
+
+	return newSearchableDropDown(labels, nil, selected)
+}
+
+func newSearchableDropDown(labels []string, disabled DropDownDisabledFunc, onSelected func(int)) *gtk.DropDown {
+	if disabled == nil {
+		disabled = func(int) (string, bool) { return "", false }
+	}
+
+	model := gtk.NewStringList(labels)
+	expr := gtk.NewPropertyExpression(gtk.GTypeStringObject, nil, "string")
+
+	// The popover shows a filtered model while searching, so list item
+	// positions don't match the indices of labels. Map each item back to its
+	// index instead.
+	indices := make(map[uintptr]int, len(labels))
+	for i := range labels {
+		indices[model.Item(uint(i)).Native()] = i
+	}
+	disabledItem := func(obj *glib.Object) (string, bool) {
+		i, ok := indices[obj.Native()]
+		if !ok {
+			return "", false
+		}
+		return disabled(i)
+	}
+
+	dropdown := gtk.NewDropDown(model, expr)
+	dropdown.SetFactory(newDropDownFactory(true, nil))
+	dropdown.SetListFactory(newDropDownFactory(false, disabledItem))
+	dropdown.SetEnableSearch(true)
+	dropdown.SetSearchMatchMode(gtk.StringFilterMatchModeSubstring)
+
+	last := dropdown.Selected()
+	dropdown.NotifyProperty("selected", func() {
+		i := dropdown.Selected()
+		if i == gtk.InvalidListPosition {
+			return
+		}
+
+		if _, ok := disabled(int(i)); ok {
+			if last != i {
+				dropdown.SetSelected(last)
+			}
+			return
+		}
+
+		last = i
+		if onSelected != nil {
+			onSelected(int(i))
+		}
+	})
+
+	bindTypeAhead(dropdown, labels, disabled)
+	return dropdown
+}
+
+// newDropDownFactory creates a factory of labels for a dropdown of
+// gtk.StringObjects. If ellipsize is true, then the labels are ellipsized
+// instead of making the dropdown wider. If disabled is not nil, then items
+// that it reports as disabled are greyed out and cannot be activated.
+func newDropDownFactory(ellipsize bool, disabled func(item *glib.Object) (reason string, disabled bool)) *gtk.ListItemFactory {
+	factory := gtk.NewSignalListItemFactory()
+	factory.ConnectSetup(func(obj *glib.Object) {
+		label := gtk.NewLabel("")
+		label.SetXAlign(0)
+		if ellipsize {
+			label.SetEllipsize(pango.EllipsizeEnd)
+		}
+
+		item := obj.Cast().(*gtk.ListItem)
+		item.SetChild(label)
+	})
+	factory.ConnectBind(func(obj *glib.Object) {
+		item := obj.Cast().(*gtk.ListItem)
+		str := item.Item().Cast().(*gtk.StringObject)
+
+		label := item.Child().(*gtk.Label)
+		label.SetText(str.String())
+
+		if disabled != nil {
+			reason, ok := disabled(item.Item())
+			label.SetSensitive(!ok)
+			label.SetTooltipText(reason)
+			item.SetActivatable(!ok)
+			item.SetSelectable(!ok)
+		}
+	})
+	return &factory.ListItemFactory
+}
+
+// bindTypeAhead makes typing while the dropdown is focused select the next
+// item that starts with the typed text, like a combo box.
+func bindTypeAhead(dropdown *gtk.DropDown, labels []string, disabled DropDownDisabledFunc) {
+	folded := make([]string, len(labels))
+	for i, label := range labels {
+		folded[i] = strings.ToLower(label)
+	}
+
+	var typed string
+	var lastTyped time.Time
+
+	key := gtk.NewEventControllerKey()
+	key.ConnectKeyPressed(func(keyval, _ uint, state gdk.ModifierType) bool {
+		if state&(gdk.ControlMask|gdk.AltMask|gdk.SuperMask) != 0 {
+			return false
+		}
+
+		r := rune(gdk.KeyvalToUnicode(keyval))
+		if r == 0 || !unicode.IsPrint(r) {
+			return false
+		}
+
+		now := time.Now()
+		if now.Sub(lastTyped) > typeAheadTimeout {
+			typed = ""
+		}
+
+		if typed == "" && r == ' ' {
+			// Let Space open the popover.
+			return false
+		}
+
+		typed += strings.ToLower(string(r))
+		lastTyped = now
+
+		// Search from the current item so that typing more of its text
+		// keeps it selected, but typing a new first letter cycles through
+		// the items that start with it.
+		start := 0
+		if i := dropdown.Selected(); i != gtk.InvalidListPosition {
+			start = int(i)
+			if len(typed) == 1 {
+				start++
+			}
+		}
+
+		for n := range folded {
+			i := (start + n) % len(folded)
+			if _, ok := disabled(i); ok {
+				continue
+			}
+			if strings.HasPrefix(folded[i], typed) {
+				dropdown.SetSelected(uint(i))
+				break
+			}
+		}
+
+		return true
+	})
+	dropdown.AddController(key)
+}
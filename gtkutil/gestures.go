@@ -70,6 +70,7 @@ func ForwardTypingFunc(w gtk.Widgetter, f func() gtk.Widgetter) {
 // AddCallbackShortcuts adds the given shortcuts to the widget. The shortcuts
 // are given as a map of keybindings to callbacks.
 func AddCallbackShortcuts(w gtk.Widgetter, shortcuts map[string]func()) {
+	recordShortcuts(shortcuts)
 	controller := gtk.NewShortcutController()
 
 	for key, callback := range shortcuts {
@@ -93,6 +94,7 @@ func AddCallbackShortcuts(w gtk.Widgetter, shortcuts map[string]func()) {
 // AddActionShortcuts adds the given shortcuts to the widget. The shortcuts are
 // given as a map of keybindings to action names.
 func AddActionShortcuts(w gtk.Widgetter, shortcuts map[string]string) {
+	recordShortcuts(shortcuts)
 	controller := gtk.NewShortcutController()
 
 	for key, actionName := range shortcuts {
@@ -108,3 +110,46 @@ func AddActionShortcuts(w gtk.Widgetter, shortcuts map[string]string) {
 
 	gtk.BaseWidget(w).AddController(controller)
 }
+
+// Closer describes a window that can be closed, such as gtk.Window or
+// adw.ApplicationWindow.
+type Closer interface {
+	gtk.Widgetter
+	Close()
+	ConnectCloseRequest(func() bool) glib.SignalHandle
+}
+
+var (
+	_ Closer = (*gtk.Window)(nil)
+	_ Closer = (*gtk.Dialog)(nil)
+)
+
+// BindClose binds the given keys to close the window. If no keys are given,
+// then Escape is used.
+func BindClose(win Closer, keys ...string) {
+	BindCloseGuarded(win, nil, keys...)
+}
+
+// BindCloseGuarded is like BindClose, except guard is called whenever the
+// window is about to be closed, whether it's by one of the keys or otherwise.
+// If guard returns false, then the window is not closed. A guard that asks the
+// user first should make sure that it returns true the next time before
+// calling Close again.
+func BindCloseGuarded(win Closer, guard func() bool, keys ...string) {
+	if len(keys) == 0 {
+		keys = []string{"Escape"}
+	}
+
+	shortcuts := make(map[string]func(), len(keys))
+	for _, key := range keys {
+		shortcuts[key] = win.Close
+	}
+	AddCallbackShortcuts(win, shortcuts)
+
+	if guard != nil {
+		win.ConnectCloseRequest(func() bool {
+			// Returning true stops the window from closing.
+			return !guard()
+		})
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
 	"reflect"
+	"sync"
 	"sync/atomic"
 
 	"github.com/diamondburned/gotk4/pkg/gdk/v4"
@@ -159,6 +161,24 @@ func RemoveChildren(w gtk.Widgetter) {
 	}
 }
 
+// SingleClickActivate makes the list activate its rows with a single click and
+// calls f with the activated row. Rows can also be activated using the keyboard
+// by pressing Enter or Space while they're focused, which calls f the same way.
+// Rows that are not activatable or not sensitive are never activated.
+//
+// Activating a row is separate from selecting it: the list's selection mode is
+// left as-is, and f is only called for activation. A clicked row is focused, so
+// keyboard navigation continues from it.
+func SingleClickActivate(list *gtk.ListBox, f func(row *gtk.ListBoxRow)) {
+	list.SetActivateOnSingleClick(true)
+	list.ConnectRowActivated(func(row *gtk.ListBoxRow) {
+		if !row.HasFocus() {
+			row.GrabFocus()
+		}
+		f(row)
+	})
+}
+
 // BindKeys binds the event controller returned from NewKeybinds being given the
 // map to the given widget.
 func BindKeys(w gtk.Widgetter, accelFns map[string]func() bool) {
@@ -176,6 +196,7 @@ func NewKeybinds(accelFns map[string]func() bool) *gtk.EventControllerKey {
 	}
 
 	bindFns := make(map[key]func() bool, len(accelFns))
+	recordShortcuts(accelFns)
 
 	for accel, fn := range accelFns {
 		val, mods, ok := gtk.AcceleratorParse(accel)
@@ -214,6 +235,35 @@ func OnFirstMap(w gtk.Widgetter, f func()) {
 	})
 }
 
+// RestoreFocusOnClose makes the widget that has the focus in trigger's window
+// grab the focus again once dialog is hidden, such as when it's closed. It
+// should be called right before dialog is shown. If the focused widget is gone
+// or cannot grab the focus by then, trigger grabs the focus instead.
+func RestoreFocusOnClose(dialog, trigger gtk.Widgetter) {
+	triggerWidget := gtk.BaseWidget(trigger)
+
+	var focused gtk.Widgetter
+	if root := triggerWidget.Root(); root != nil {
+		focused = root.Focus()
+	}
+
+	widget := gtk.BaseWidget(dialog)
+
+	var handle glib.SignalHandle
+	handle = widget.ConnectHide(func() {
+		widget.HandlerDisconnect(handle)
+
+		if focused != nil {
+			w := gtk.BaseWidget(focused)
+			if w.Root() != nil && w.GrabFocus() {
+				return
+			}
+		}
+
+		triggerWidget.GrabFocus()
+	})
+}
+
 // OnFirstDraw attaches f to be called on the first time the widget is drawn on
 // the screen.
 func OnFirstDraw(w gtk.Widgetter, f func()) {
@@ -273,6 +323,56 @@ func BindSubscribe(widget gtk.Widgetter, f func() (unsub func())) {
 	})
 }
 
+// IsRTL returns true if the application's default text direction is
+// right-to-left, which is the case for locales such as Arabic and Hebrew.
+// Widgets that have their direction overridden should use WidgetIsRTL instead.
+func IsRTL() bool {
+	return gtk.WidgetGetDefaultDirection() == gtk.TextDirRTL
+}
+
+// WidgetIsRTL returns true if the given widget is laid out right-to-left. Most
+// widgets inherit the application's default direction, but it can be
+// overridden using SetDirection.
+func WidgetIsRTL(w gtk.Widgetter) bool {
+	return gtk.BaseWidget(w).Direction() == gtk.TextDirRTL
+}
+
+// BindAspectRatio makes the widget request a height proportional to its
+// allocated width, where ratio is the width divided by the height. This is
+// useful for reserving space for images with a known aspect ratio before they
+// are loaded, so the layout doesn't jump once they are. The widget's own height
+// request is overridden.
+//
+// The height is updated after the widget is allocated a new width, so it lags
+// behind by a frame when the width changes.
+func BindAspectRatio(w gtk.Widgetter, ratio float64) {
+	if ratio <= 0 {
+		log.Panicf("gtkutil: invalid aspect ratio %v", ratio)
+	}
+
+	widget := gtk.BaseWidget(w)
+	lastWidth := -1
+
+	update := func() {
+		width := widget.Width()
+		if width == lastWidth {
+			return
+		}
+		lastWidth = width
+
+		minWidth, _ := widget.SizeRequest()
+		widget.SetSizeRequest(minWidth, int(math.Round(float64(width)/ratio)))
+	}
+
+	BindSubscribe(widget, func() func() {
+		clock := gdk.BaseFrameClock(widget.FrameClock())
+		handle := clock.ConnectLayout(update)
+		update()
+
+		return func() { clock.HandlerDisconnect(handle) }
+	})
+}
+
 // NotifyProperty calls f everytime the object's property changes until it
 // returns true.
 func NotifyProperty(obj glib.Objector, property string, f func() bool) {
@@ -306,6 +406,81 @@ func InvokeMain(f func()) {
 	<-done
 }
 
+// MainContext lets a goroutine run code in the main loop and wait for it, which
+// is useful for background work that alternates between the goroutine and the
+// main loop, such as reading a widget's state, doing work with it, then
+// updating the widget:
+//
+//	go func() {
+//		main := gtkutil.WithMainContext(ctx)
+//
+//		var query string
+//		if err := main.Do(func() { query = entry.Text() }); err != nil {
+//			return
+//		}
+//
+//		results := search(ctx, query)
+//		main.Do(func() { list.SetResults(results) })
+//	}()
+type MainContext struct {
+	ctx context.Context
+}
+
+// WithMainContext creates a new MainContext that stops running code in the main
+// loop once ctx is cancelled.
+func WithMainContext(ctx context.Context) MainContext {
+	return MainContext{ctx}
+}
+
+// Context returns the MainContext's context.
+func (m MainContext) Context() context.Context {
+	return m.ctx
+}
+
+// Do runs f in the main loop and waits for it to return. If Do is called from
+// the main loop, then f is called right away. If the context is cancelled
+// before f gets to run, then f is never called and the context's error is
+// returned; once f starts running, Do always waits for it.
+func (m MainContext) Do(f func()) error {
+	if err := m.ctx.Err(); err != nil {
+		return err
+	}
+
+	if mainThread.IsOwner() {
+		f()
+		return nil
+	}
+
+	const (
+		pending int32 = iota
+		running
+		abandoned
+	)
+
+	var state atomic.Int32
+	done := make(chan struct{})
+
+	mainThread.InvokeFull(int(coreglib.PriorityHigh), func() bool {
+		if state.CompareAndSwap(pending, running) {
+			f()
+			close(done)
+		}
+		return false
+	})
+
+	select {
+	case <-done:
+		return nil
+	case <-m.ctx.Done():
+		if state.CompareAndSwap(pending, abandoned) {
+			return m.ctx.Err()
+		}
+		// f is already running, so it must be waited for.
+		<-done
+		return nil
+	}
+}
+
 // Async runs asyncFn in a goroutine and runs the returned callback in the main
 // thread. If ctx is cancelled during, the returned callback will not be called.
 func Async(ctx context.Context, asyncFn func() func()) {
@@ -327,13 +502,7 @@ func Async(ctx context.Context, asyncFn func() func()) {
 		default:
 		}
 
-		coreglib.IdleAdd(func() {
-			select {
-			case <-ctx.Done():
-			default:
-				fn()
-			}
-		})
+		IdleAddContext(ctx, fn)
 	}()
 }
 
@@ -364,6 +533,56 @@ retry:
 		// someone else updated the scale factor, retry
 		goto retry
 	}
+
+	if maxScale != int(scale) {
+		notifyScaleFactor(maxScale)
+	}
+}
+
+var scaleFactorSubs struct {
+	sync.Mutex
+	funcs map[*func(int)]struct{}
+}
+
+// OnScaleFactorChanged adds f to be called in the main thread every time the
+// value returned by ScaleFactor changes, such as when a window is dragged onto
+// a HiDPI display. The returned function removes f.
+func OnScaleFactorChanged(f func(int)) (unsub func()) {
+	initScale()
+
+	k := &f
+
+	scaleFactorSubs.Lock()
+	if scaleFactorSubs.funcs == nil {
+		scaleFactorSubs.funcs = make(map[*func(int)]struct{})
+	}
+	scaleFactorSubs.funcs[k] = struct{}{}
+	scaleFactorSubs.Unlock()
+
+	return func() {
+		scaleFactorSubs.Lock()
+		delete(scaleFactorSubs.funcs, k)
+		scaleFactorSubs.Unlock()
+	}
+}
+
+func notifyScaleFactor(scale int) {
+	scaleFactorSubs.Lock()
+	funcs := make([]*func(int), 0, len(scaleFactorSubs.funcs))
+	for f := range scaleFactorSubs.funcs {
+		funcs = append(funcs, f)
+	}
+	scaleFactorSubs.Unlock()
+
+	if len(funcs) == 0 {
+		return
+	}
+
+	InvokeMain(func() {
+		for _, f := range funcs {
+			(*f)(scale)
+		}
+	})
 }
 
 func initScale() {
@@ -386,7 +605,17 @@ func initScale() {
 
 func bindDisplay(display *gdk.Display) {
 	monitors := display.Monitors()
-	monitors.ConnectItemsChanged(func(_, _, _ uint) { updateScale() })
+	monitors.ConnectItemsChanged(func(position, _, added uint) {
+		for i := position; i < position+added; i++ {
+			bindMonitor(monitors.Item(i).Cast().(*gdk.Monitor))
+		}
+		updateScale()
+	})
+	EachList(monitors, bindMonitor)
+}
+
+func bindMonitor(monitor *gdk.Monitor) {
+	monitor.NotifyProperty("scale-factor", updateScale)
 }
 
 func updateScale() {
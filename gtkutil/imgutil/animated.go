@@ -0,0 +1,251 @@
+package imgutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/gdkpixbuf/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotkit/gtkutil/mediautil"
+	"github.com/pkg/errors"
+)
+
+// animatedFrameRate is the frame rate that animations decoded using FFmpeg are
+// resampled to, since PixbufSimpleAnim can only play at a fixed frame rate.
+const animatedFrameRate = 20
+
+// maxAnimatedFrames is the maximum number of frames decoded using FFmpeg. The
+// frames are kept uncompressed in memory, so longer animations are cut short.
+const maxAnimatedFrames = 300
+
+// maxAnimatedSize is the maximum width and height of animations decoded using
+// FFmpeg, regardless of the size set using WithMaxSize. The size comes from the
+// untrusted image header, so it must always be bounded.
+const maxAnimatedSize = 1024
+
+// maxAnimatedMemory is the maximum number of bytes of decoded frames kept for
+// an animation decoded using FFmpeg. Fewer frames are decoded for larger
+// animations.
+const maxAnimatedMemory = 128 << 20 // 128MB
+
+// maxAnimatedData is the maximum size of an animated image that is buffered in
+// memory to be decoded using FFmpeg. Larger images are not decoded using
+// FFmpeg.
+const maxAnimatedData = 32 << 20 // 32MB
+
+// animatedMIMEs are the MIME types of formats that may be animated but that
+// gdk-pixbuf often can't animate, depending on the installed loaders.
+var animatedMIMEs = map[string]struct{}{
+	"image/webp": {},
+	"image/png":  {},
+	"image/apng": {},
+}
+
+// cappedBuffer is a bytes.Buffer that stops buffering once it would grow past
+// max bytes. Writes never fail, so it can be used with io.TeeReader and as the
+// stdout of a process without interrupting them.
+type cappedBuffer struct {
+	bytes.Buffer
+	max  int
+	over bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.over || b.Len()+len(p) > b.max {
+		if !b.over {
+			b.over = true
+			b.Buffer = bytes.Buffer{}
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// headerPeeker is implemented by the reader returned by
+// mediautil.MIMEBuffered.
+type headerPeeker interface {
+	Peek(n int) ([]byte, error)
+}
+
+// animatedSize returns the size of the image if the given header belongs to
+// an animated WebP or APNG.
+func animatedSize(mime string, header []byte) (w, h int, ok bool) {
+	if _, ok := animatedMIMEs[mime]; !ok {
+		return 0, 0, false
+	}
+
+	switch {
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return apngSize(header)
+	case len(header) >= 16 &&
+		string(header[0:4]) == "RIFF" &&
+		string(header[8:12]) == "WEBP":
+		return webpSize(header)
+	default:
+		return 0, 0, false
+	}
+}
+
+// apngSize returns the size of the PNG if it has an acTL chunk, which must
+// come before the first IDAT chunk in an APNG.
+func apngSize(header []byte) (w, h int, ok bool) {
+	for i := 8; i+8 <= len(header); {
+		length := int(binary.BigEndian.Uint32(header[i:]))
+		chunk := string(header[i+4 : i+8])
+
+		switch chunk {
+		case "IHDR":
+			if i+16 > len(header) {
+				return 0, 0, false
+			}
+			w = int(binary.BigEndian.Uint32(header[i+8:]))
+			h = int(binary.BigEndian.Uint32(header[i+12:]))
+		case "acTL":
+			return w, h, w > 0 && h > 0
+		case "IDAT":
+			return 0, 0, false
+		}
+
+		// Skip the length, type, data and CRC.
+		i += length + 12
+	}
+	return 0, 0, false
+}
+
+// webpSize returns the canvas size of the WebP if its VP8X chunk has the
+// animation flag set.
+func webpSize(header []byte) (w, h int, ok bool) {
+	if len(header) < 30 || string(header[12:16]) != "VP8X" {
+		return 0, 0, false
+	}
+
+	const animationFlag = 0x02
+	if header[20]&animationFlag == 0 {
+		return 0, 0, false
+	}
+
+	// The canvas size is stored as 24-bit integers minus one.
+	w = 1 + (int(header[24]) | int(header[25])<<8 | int(header[26])<<16)
+	h = 1 + (int(header[27]) | int(header[28])<<8 | int(header[29])<<16)
+	return w, h, true
+}
+
+// fileAnimation decodes the image file at path using FFmpeg if it is an
+// animated WebP or APNG. Nil is returned if it isn't or if it can't be decoded.
+func fileAnimation(ctx context.Context, path string, o Opts) *gdkpixbuf.PixbufAnimation {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	mime := mediautil.MIME(f)
+
+	header := make([]byte, 512)
+	n, _ := io.ReadFull(f, header)
+
+	w, h, ok := animatedSize(mime, header[:n])
+	if !ok {
+		return nil
+	}
+
+	return ffmpegAnimation(ctx, path, nil, w, h, o)
+}
+
+// ffmpegAnimation decodes the frames of an animated image of the given size
+// using FFmpeg. The image is read from data if it's not nil, otherwise from the
+// file at src. Nil is returned if FFmpeg isn't available or fails.
+func ffmpegAnimation(ctx context.Context, src string, data []byte, w, h int, o Opts) *gdkpixbuf.PixbufAnimation {
+	logger := slog.Default().With(
+		"src", src,
+		"module", "imgutil.ffmpegAnimation")
+
+	if !ffmpegAvailable() {
+		logger.Debug("cannot decode animation since ffmpeg is not available")
+		return nil
+	}
+
+	anim, err := decodeFFmpegAnimation(ctx, src, data, w, h, o)
+	if err != nil {
+		logger.Debug("cannot decode animation using ffmpeg", "err", err)
+		return nil
+	}
+
+	return anim
+}
+
+func decodeFFmpegAnimation(ctx context.Context, src string, data []byte, w, h int, o Opts) (*gdkpixbuf.PixbufAnimation, error) {
+	if o.w > 0 && o.h > 0 {
+		w, h = MaxSize(w, h, o.w, o.h)
+	}
+	w, h = MaxSize(w, h, maxAnimatedSize, maxAnimatedSize)
+	w, h = max(w, 1), max(h, 1)
+
+	frameSize := w * h * 4
+	maxFrames := min(maxAnimatedFrames, maxAnimatedMemory/frameSize)
+	if maxFrames < 2 {
+		return nil, fmt.Errorf("animation of size %dx%d is too large", w, h)
+	}
+
+	if err := ffmpegSema.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	defer ffmpegSema.Release(1)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if data != nil {
+		src = "pipe:0"
+	}
+
+	stdout := cappedBuffer{max: maxFrames * frameSize}
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-loglevel", "warning",
+		"-i", src,
+		"-vf", fmt.Sprintf("fps=%d,scale=%d:%d", animatedFrameRate, w, h),
+		"-frames:v", strconv.Itoa(maxFrames),
+		"-f", "rawvideo",
+		"-pix_fmt", "rgba",
+		"pipe:1",
+	)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if data != nil {
+		cmd.Stdin = bytes.NewReader(data)
+	}
+
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "ffmpeg failed: %s", bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	if stdout.over {
+		return nil, fmt.Errorf("ffmpeg output more than %d frames", maxFrames)
+	}
+
+	frames := stdout.Len() / frameSize
+	if frames < 2 {
+		return nil, fmt.Errorf("ffmpeg decoded %d frames, not an animation", frames)
+	}
+
+	anim := gdkpixbuf.NewPixbufSimpleAnim(w, h, animatedFrameRate)
+	anim.SetLoop(true)
+
+	for i := 0; i < frames; i++ {
+		frame := glib.NewBytes(stdout.Next(frameSize))
+		anim.AddFrame(gdkpixbuf.NewPixbufFromBytes(
+			frame, gdkpixbuf.ColorspaceRGB, true, 8, w, h, w*4))
+	}
+
+	return &anim.PixbufAnimation, nil
+}
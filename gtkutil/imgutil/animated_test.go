@@ -0,0 +1,76 @@
+package imgutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// pngHeader returns the start of a PNG with an IHDR chunk of the given size
+// followed by empty chunks of the given types.
+func pngHeader(w, h uint32, chunks ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+
+	binary.Write(&buf, binary.BigEndian, uint32(13))
+	buf.WriteString("IHDR")
+	binary.Write(&buf, binary.BigEndian, w)
+	binary.Write(&buf, binary.BigEndian, h)
+	buf.Write([]byte{8, 6, 0, 0, 0}) // depth, color type, etc.
+	buf.Write(make([]byte, 4))       // CRC
+
+	for _, chunk := range chunks {
+		binary.Write(&buf, binary.BigEndian, uint32(0))
+		buf.WriteString(chunk)
+		buf.Write(make([]byte, 4))
+	}
+
+	return buf.Bytes()
+}
+
+// webpHeader returns the start of an extended WebP of the given size.
+func webpHeader(w, h int, animated bool) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(0))
+	buf.WriteString("WEBPVP8X")
+	binary.Write(&buf, binary.LittleEndian, uint32(10))
+
+	var flags byte
+	if animated {
+		flags |= 0x02
+	}
+	buf.Write([]byte{flags, 0, 0, 0})
+
+	for _, v := range []int{w - 1, h - 1} {
+		buf.Write([]byte{byte(v), byte(v >> 8), byte(v >> 16)})
+	}
+
+	return buf.Bytes()
+}
+
+func TestAnimatedSize(t *testing.T) {
+	tests := []struct {
+		name   string
+		mime   string
+		header []byte
+		w, h   int
+		ok     bool
+	}{
+		{"apng", "image/png", pngHeader(300, 200, "acTL", "IDAT"), 300, 200, true},
+		{"png", "image/png", pngHeader(300, 200, "IDAT", "acTL"), 0, 0, false},
+		{"animated webp", "image/webp", webpHeader(1000, 70000, true), 1000, 70000, true},
+		{"static webp", "image/webp", webpHeader(1000, 700, false), 0, 0, false},
+		{"wrong mime", "image/gif", webpHeader(1000, 700, true), 0, 0, false},
+		{"truncated", "image/webp", webpHeader(1000, 700, true)[:20], 0, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w, h, ok := animatedSize(test.mime, test.header)
+			if ok != test.ok || w != test.w || h != test.h {
+				t.Errorf("got %dx%d (%v), want %dx%d (%v)", w, h, ok, test.w, test.h, test.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package imgutil
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/semaphore"
+)
+
+// decoders is used to throttle concurrent image decodes. It is separate from
+// parallel, so CPU-bound decoding never holds up downloads, and a burst of
+// finished downloads doesn't decode all at once.
+var decoders = semaphore.NewWeighted(int64(runtime.GOMAXPROCS(-1)))
+
+// decode calls f, which decodes an image, once a decoder slot is free. It
+// must not be called with a download slot held.
+func decode(ctx context.Context, f func() error) error {
+	if err := decoders.Acquire(ctx, 1); err != nil {
+		return errors.Wrap(err, "failed to acquire decoder")
+	}
+	defer decoders.Release(1)
+
+	return f()
+}
@@ -0,0 +1,109 @@
+package imgutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/utils/cachegc"
+	"github.com/diamondburned/gotkit/utils/osutil"
+	"github.com/pkg/errors"
+)
+
+var contentDedup atomic.Bool
+
+// SetContentDedup sets whether downloaded images are deduplicated on disk by
+// their content. When enabled, each image is stored once under the hash of its
+// bytes, and the cache file for its URL becomes a small pointer to it. This
+// saves disk space when the same image is served from multiple URLs at the
+// cost of hashing every download.
+//
+// Images cached before this is enabled are still used. This function can be
+// called from any thread.
+func SetContentDedup(dedup bool) {
+	contentDedup.Store(dedup)
+}
+
+// refSuffix is the suffix of the pointer files that refer to an image in the
+// content cache.
+const refSuffix = ".ref"
+
+func contentCacheDir(ctx context.Context) string {
+	return app.FromContext(ctx).CachePath("img2-content")
+}
+
+// cachedPath returns the path to the cached image for cacheDst, following its
+// pointer file into the content cache if there is one. False is returned if
+// the image isn't cached.
+func cachedPath(ctx context.Context, cacheDst string) (string, bool) {
+	if _, err := os.Stat(cacheDst); err == nil {
+		return cacheDst, true
+	}
+
+	b, err := os.ReadFile(cacheDst + refSuffix)
+	if err != nil {
+		return "", false
+	}
+
+	path := filepath.Join(contentCacheDir(ctx), strings.TrimSpace(string(b)))
+	if _, err := os.Stat(path); err != nil {
+		// The content was garbage-collected from under us, so the pointer is
+		// useless now.
+		os.Remove(cacheDst + refSuffix)
+		return "", false
+	}
+
+	return path, true
+}
+
+// fetchURLDedup downloads the URL into the content cache and points cacheDst
+// to it.
+func fetchURLDedup(ctx context.Context, url, cacheDst string) error {
+	contentDir := contentCacheDir(ctx)
+
+	if err := os.MkdirAll(contentDir, os.ModePerm); err != nil {
+		return errors.Wrap(err, "cannot mkdir -p content cache")
+	}
+
+	f, err := os.CreateTemp(contentDir, ".tmp.*")
+	if err != nil {
+		return errors.Wrap(err, "cannot mktemp")
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	hash := sha256.New()
+	if err := downloadTo(ctx, url, io.MultiWriter(f, hash)); err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "cannot close downloaded file")
+	}
+
+	name := base64.URLEncoding.EncodeToString(hash.Sum(nil))
+	path := filepath.Join(contentDir, name)
+
+	if _, err := os.Stat(path); err == nil {
+		// We already have this image from another URL. Bump its modification
+		// time so that it outlives the new pointer.
+		now := time.Now()
+		os.Chtimes(path, now, now)
+	} else if err := os.Rename(f.Name(), path); err != nil {
+		return errors.Wrap(err, "cannot move downloaded file into content cache")
+	}
+
+	cachegc.Do(contentDir, CacheAge)
+
+	return osutil.UseFileWithPattern(cacheDst+refSuffix, "*", func(f *os.File) error {
+		_, err := io.WriteString(f, name)
+		return err
+	})
+}
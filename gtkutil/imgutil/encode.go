@@ -0,0 +1,46 @@
+package imgutil
+
+import (
+	"strconv"
+
+	"github.com/diamondburned/gotk4/pkg/gdkpixbuf/v2"
+	"github.com/pkg/errors"
+)
+
+// PNGCompression is the zlib compression level used by EncodePNG. It trades a
+// bit of size for encoding speed compared to the maximum of 9.
+const PNGCompression = 6
+
+// DefaultJPEGQuality is the quality used by EncodeJPEG if the given quality is
+// not within [1, 100].
+const DefaultJPEGQuality = 85
+
+// EncodePNG encodes the pixbuf as PNG using PNGCompression.
+func EncodePNG(p *gdkpixbuf.Pixbuf) ([]byte, error) {
+	b, err := p.SaveToBufferv("png",
+		[]string{"compression"},
+		[]string{strconv.Itoa(PNGCompression)},
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot encode PNG")
+	}
+	return b, nil
+}
+
+// EncodeJPEG encodes the pixbuf as JPEG with the given quality from 1 to 100.
+// If quality is out of range, then DefaultJPEGQuality is used. JPEG has no
+// transparency, so the alpha channel is dropped.
+func EncodeJPEG(p *gdkpixbuf.Pixbuf, quality int) ([]byte, error) {
+	if quality < 1 || quality > 100 {
+		quality = DefaultJPEGQuality
+	}
+
+	b, err := p.SaveToBufferv("jpeg",
+		[]string{"quality"},
+		[]string{strconv.Itoa(quality)},
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot encode JPEG")
+	}
+	return b, nil
+}
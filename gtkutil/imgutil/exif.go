@@ -0,0 +1,13 @@
+package imgutil
+
+import "github.com/diamondburned/gotk4/pkg/gdkpixbuf/v2"
+
+// orientedPixbuf returns the pixbuf with its embedded EXIF orientation
+// applied. The orientation is only embedded by gdk-pixbuf's loaders, which
+// don't apply it on their own.
+func orientedPixbuf(p *gdkpixbuf.Pixbuf) *gdkpixbuf.Pixbuf {
+	if oriented := p.ApplyEmbeddedOrientation(); oriented != nil {
+		return oriented
+	}
+	return p
+}
@@ -0,0 +1,93 @@
+package imgutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/gdkpixbuf/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+// exifOrientationTag is the EXIF tag of the image orientation.
+const exifOrientationTag = 0x0112
+
+// rotatedJPEG returns a small JPEG carrying the given EXIF orientation, the
+// same way phone cameras store rotated photos.
+func rotatedJPEG(t *testing.T, orientation uint16) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 2)), nil); err != nil {
+		t.Fatal("cannot encode JPEG:", err)
+	}
+
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8)) // IFD0 offset
+	binary.Write(&tiff, binary.BigEndian, uint16(1)) // entry count
+	binary.Write(&tiff, binary.BigEndian, uint16(exifOrientationTag))
+	binary.Write(&tiff, binary.BigEndian, uint16(3)) // SHORT
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, orientation)
+	binary.Write(&tiff, binary.BigEndian, uint16(0)) // padding
+	binary.Write(&tiff, binary.BigEndian, uint32(0)) // next IFD
+
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+
+	var app1 bytes.Buffer
+	app1.Write([]byte{0xFF, 0xE1})
+	binary.Write(&app1, binary.BigEndian, uint16(2+len(segment)))
+	app1.Write(segment)
+
+	data := buf.Bytes()
+	// Insert the APP1 segment right after the SOI marker.
+	return append(append(append([]byte{}, data[:2]...), app1.Bytes()...), data[2:]...)
+}
+
+// TestLoadPixbufOrientation loads rotated JPEGs the same way fetched images are
+// loaded and checks that they come out upright.
+func TestLoadPixbufOrientation(t *testing.T) {
+	// The sample is 4x2, so orientations that rotate it by 90 degrees make it
+	// 2x4.
+	tests := []struct {
+		orientation uint16
+		w, h        int
+	}{
+		{1, 4, 2},
+		{3, 4, 2},
+		{6, 2, 4},
+		{8, 2, 4},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint("orientation", test.orientation), func(t *testing.T) {
+			var got *gdkpixbuf.Pixbuf
+			setter := ImageSetter{
+				SetFromPixbuf: func(p *gdkpixbuf.Pixbuf) { got = p },
+			}
+
+			data := rotatedJPEG(t, test.orientation)
+			if err := loadPixbuf(context.Background(), bytes.NewReader(data), setter, Opts{}); err != nil {
+				t.Fatal("cannot load image:", err)
+			}
+
+			// The pixbuf is set in the main loop.
+			main := glib.MainContextDefault()
+			for deadline := time.Now().Add(5 * time.Second); got == nil; {
+				if time.Now().After(deadline) {
+					t.Fatal("timed out waiting for the image to be set")
+				}
+				main.Iteration(false)
+			}
+
+			if w, h := got.Width(), got.Height(); w != test.w || h != test.h {
+				t.Errorf("expected size %dx%d, got %dx%d", test.w, test.h, w, h)
+			}
+		})
+	}
+}
@@ -62,12 +62,14 @@ func (p FFmpegOpts) Do(ctx context.Context, url *url.URL, img ImageSetter) {
 			o.Error(errors.Wrap(err, "cannot create pixbuf"))
 			return
 		}
+		p = orientedPixbuf(p)
 
 		glib.IdleAdd(func() {
-			select {
-			case <-ctx.Done():
-				o.Error(ctx.Err())
-			default:
+			// Still report the cancellation, so that the done callbacks are
+			// always called.
+			if err := ctx.Err(); err != nil {
+				o.Error(err)
+				return
 			}
 
 			switch {
@@ -76,6 +78,8 @@ func (p FFmpegOpts) Do(ctx context.Context, url *url.URL, img ImageSetter) {
 			case img.SetFromPaintable != nil:
 				img.SetFromPaintable(gdk.NewTextureForPixbuf(p))
 			}
+
+			o.onDone(nil)
 		})
 	}()
 }
@@ -85,15 +89,19 @@ var (
 	ffmpegOnce sync.Once
 )
 
-// FFmpegThumbnail fetches the thumbnail of the given URL and returns the path
-// to the file. If format is empty, then jpeg is used.
-func FFmpegThumbnail(ctx context.Context, format, url string) (string, error) {
+// ffmpegAvailable returns true if the ffmpeg binary is in $PATH.
+func ffmpegAvailable() bool {
 	ffmpegOnce.Do(func() {
 		ffmpeg, _ := exec.LookPath("ffmpeg")
 		hasFFmpeg = ffmpeg != ""
 	})
+	return hasFFmpeg
+}
 
-	if !hasFFmpeg {
+// FFmpegThumbnail fetches the thumbnail of the given URL and returns the path
+// to the file. If format is empty, then jpeg is used.
+func FFmpegThumbnail(ctx context.Context, format, url string) (string, error) {
+	if !ffmpegAvailable() {
 		return "", nil
 	}
 
@@ -109,6 +117,10 @@ func FFmpegThumbnail(ctx context.Context, format, url string) (string, error) {
 		return thumbDst, nil
 	}
 
+	if IsOfflineMode() && isRemoteURL(url) {
+		return "", ErrOffline
+	}
+
 	if err := ffmpegSema.Acquire(ctx, 1); err != nil {
 		return thumbDst, err
 	}
@@ -122,6 +134,13 @@ func FFmpegThumbnail(ctx context.Context, format, url string) (string, error) {
 	return thumbDst, err
 }
 
+// isRemoteURL returns true if FFmpeg would fetch the given input over the
+// network. Local files may be given either as plain paths or as file URLs.
+func isRemoteURL(input string) bool {
+	u, err := url.Parse(input)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
 var ffmpegSema = semaphore.NewWeighted(int64(runtime.GOMAXPROCS(-1)))
 
 func doFFmpeg(ctx context.Context, src, dst string, opts ...string) error {
@@ -1,6 +1,7 @@
 package imgutil
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha1"
 	"encoding/base64"
@@ -12,9 +13,11 @@ import (
 	"path/filepath"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/httputil"
 	"github.com/diamondburned/gotkit/utils/cachegc"
 	"github.com/pkg/errors"
@@ -39,22 +42,91 @@ var (
 	fetchingMu   sync.Mutex
 
 	// TODO: limit the size of invalidURLs.
-	invalidURLs sync.Map
+	invalidURLs sync.Map // string -> invalidURL
 )
 
+// invalidURL is the value of invalidURLs.
+type invalidURL struct {
+	time int64 // Unix seconds
+	// transient is true if the URL failed because of a server or network
+	// error rather than because it doesn't exist.
+	transient bool
+}
+
+func init() {
+	app.Hook(func(a *app.Application) {
+		a.ConnectStartup(func() {
+			a.OnShutdown(gtkutil.OnNetworkChange(func(online bool) {
+				if online {
+					ClearTransientInvalidURLs()
+				}
+			}))
+		})
+	})
+}
+
 var errURLNotFound = errors.New("URL not found (cached)")
 
+// ErrOffline is returned when offline mode is enabled and the requested image
+// is not already in the cache. See SetOfflineMode.
+var ErrOffline = errors.New("image not cached while in offline mode")
+
+// HTTPStatusError is returned when fetching an image fails because the server
+// responded with a non-2xx status code. Callers can use errors.As to check the
+// status code, e.g. to tell apart 403 Forbidden from 404 Not Found.
+type HTTPStatusError struct {
+	StatusCode int
+	URL        string
+}
+
+// Error implements error.
+func (err *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d getting %q", err.StatusCode, err.URL)
+}
+
+var offlineMode atomic.Bool
+
+// SetOfflineMode sets whether imgutil is allowed to touch the network. When
+// offline mode is enabled, images are only ever loaded from the cache, and
+// ErrOffline is returned on a cache miss. This is useful for a "work offline"
+// toggle or for flaky networks.
+//
+// This function can be called from any thread.
+func SetOfflineMode(offline bool) {
+	offlineMode.Store(offline)
+}
+
+// IsOfflineMode returns true if offline mode is enabled.
+func IsOfflineMode() bool {
+	return offlineMode.Load()
+}
+
+const (
+	// invalidURLTimeout is how long a URL that doesn't exist isn't fetched
+	// again.
+	invalidURLTimeout = time.Hour
+	// transientURLTimeout is how long a URL that failed because of a server or
+	// network error isn't fetched again. It is short, since these errors are
+	// usually temporary; it only prevents hammering a failing server.
+	transientURLTimeout = 10 * time.Second
+)
+
 func urlIsInvalid(url string) bool {
 	h := httputil.HashURL(url)
 
-	vt, ok := invalidURLs.Load(h)
+	v, ok := invalidURLs.Load(h)
 	if !ok {
 		return false
 	}
 
-	t := time.Unix(vt.(int64), 0)
-	if t.Add(time.Hour).After(time.Now()) {
-		// fetched within the hour
+	invalid := v.(invalidURL)
+
+	timeout := invalidURLTimeout
+	if invalid.transient {
+		timeout = transientURLTimeout
+	}
+
+	if time.Unix(invalid.time, 0).Add(timeout).After(time.Now()) {
 		return true
 	}
 
@@ -62,8 +134,27 @@ func urlIsInvalid(url string) bool {
 	return false
 }
 
-func markURLInvalid(url string) {
-	invalidURLs.Store(httputil.HashURL(url), time.Now().Unix())
+func markURLInvalid(url string, transient bool) {
+	invalidURLs.Store(httputil.HashURL(url), invalidURL{
+		time:      time.Now().Unix(),
+		transient: transient,
+	})
+}
+
+// ClearTransientInvalidURLs makes URLs that recently failed to fetch because of
+// a server or network error be fetched again the next time they're requested,
+// instead of after a few seconds. URLs that failed because they don't exist
+// are still not fetched until an hour has passed. It is automatically called
+// when the system goes back online.
+//
+// This function can be called from any thread.
+func ClearTransientInvalidURLs() {
+	invalidURLs.Range(func(k, v any) bool {
+		if v.(invalidURL).transient {
+			invalidURLs.Delete(k)
+		}
+		return true
+	})
 }
 
 // FetchImageToFile fetches an image from the given URL and saves it to the
@@ -81,8 +172,12 @@ func FetchImageToFile(ctx context.Context, url string, o Opts) (string, error) {
 	cacheDir := app.FromContext(ctx).CachePath("img2")
 	cacheDst := urlPath(cacheDir, url)
 
-	if _, err := os.Stat(cacheDst); err == nil {
-		return cacheDst, nil
+	if path, ok := cachedPath(ctx, cacheDst); ok {
+		return path, nil
+	}
+
+	if IsOfflineMode() {
+		return "", ErrOffline
 	}
 
 	if err := fetchURL(ctx, url, cacheDst); err != nil {
@@ -90,7 +185,13 @@ func FetchImageToFile(ctx context.Context, url string, o Opts) (string, error) {
 	}
 
 	cachegc.Do(cacheDir, CacheAge)
-	return cacheDst, nil
+
+	path, ok := cachedPath(ctx, cacheDst)
+	if !ok {
+		return "", errors.New("image disappeared from cache after fetching")
+	}
+
+	return path, nil
 }
 
 func fetchImage(ctx context.Context, url string, img ImageSetter, o Opts) (err error) {
@@ -107,16 +208,30 @@ func fetchImage(ctx context.Context, url string, img ImageSetter, o Opts) (err e
 
 	// Perform a stat() before we call loadPixbufFromFile to prevent spurious
 	// error logging.
-	if _, err = os.Stat(cacheDst); err == nil {
-		if err = loadPixbufFromFile(ctx, cacheDst, img, o); err == nil {
+	if path, ok := cachedPath(ctx, cacheDst); ok {
+		err = decode(ctx, func() error { return loadPixbufFromFile(ctx, path, img, o) })
+		if err == nil {
 			return nil
 		}
 	}
 
+	if IsOfflineMode() {
+		// Don't bother trying to fall back to fetching without the cache,
+		// since that would hit the network as well.
+		return ErrOffline
+	}
+
 	if err = fetchURL(ctx, url, cacheDst); err == nil {
 		cachegc.Do(cacheDir, CacheAge)
+
+		path, ok := cachedPath(ctx, cacheDst)
+		if !ok {
+			path = cacheDst
+		}
+
 		// TODO: support MediaFile
-		if err = loadPixbufFromFile(ctx, cacheDst, img, o); err == nil {
+		err = decode(ctx, func() error { return loadPixbufFromFile(ctx, path, img, o) })
+		if err == nil {
 			return nil
 		}
 	}
@@ -130,13 +245,14 @@ func fetchImage(ctx context.Context, url string, img ImageSetter, o Opts) (err e
 			"url", url,
 			"path", cacheDst)
 
-		r, err := getBody(ctx, url)
-		if err != nil {
+		// Download everything first, so that a slow connection doesn't hold
+		// up a decoder.
+		var buf bytes.Buffer
+		if err := downloadTo(ctx, url, &buf); err != nil {
 			return err
 		}
-		defer r.Close()
 
-		return loadPixbuf(ctx, r, img, o)
+		return decode(ctx, func() error { return loadPixbuf(ctx, &buf, img, o) })
 	}
 
 	// Otherwise, return.
@@ -181,6 +297,13 @@ func fetchURL(ctx context.Context, url, cacheDst string) error {
 	}
 	defer parallel.Release(1)
 
+	if contentDedup.Load() {
+		if _, ok := cachedPath(ctx, cacheDst); ok {
+			return nil
+		}
+		return fetchURLDedup(ctx, url, cacheDst)
+	}
+
 	// Small time between the response being read and the file being created on
 	// the disk, which might be an issue on slow computers, but whatever.
 	return cachegc.WithTmpFile(cacheDst, "*", func(f *os.File) error {
@@ -189,6 +312,9 @@ func fetchURL(ctx context.Context, url, cacheDst string) error {
 }
 
 func downloadTo(ctx context.Context, url string, w io.Writer) error {
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+
 	r, err := getBody(ctx, url)
 	if err != nil {
 		return err
@@ -203,6 +329,10 @@ func downloadTo(ctx context.Context, url string, w io.Writer) error {
 }
 
 func getBody(ctx context.Context, url string) (io.ReadCloser, error) {
+	if IsOfflineMode() {
+		return nil, ErrOffline
+	}
+
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to create request %q", url)
@@ -212,16 +342,25 @@ func getBody(ctx context.Context, url string) (io.ReadCloser, error) {
 
 	r, err := client.Do(req)
 	if err != nil {
+		if ctx.Err() == nil {
+			markURLInvalid(url, true)
+		}
 		return nil, err
 	}
 
 	if r.StatusCode < 200 || r.StatusCode > 299 {
-		if r.StatusCode >= 400 && r.StatusCode <= 499 {
-			markURLInvalid(url)
+		switch {
+		case r.StatusCode >= 400 && r.StatusCode <= 499:
+			markURLInvalid(url, false)
+		case r.StatusCode >= 500 && r.StatusCode <= 599:
+			markURLInvalid(url, true)
 		}
 
 		r.Body.Close()
-		return nil, fmt.Errorf("unexpected status code %d getting %q", r.StatusCode, url)
+		return nil, &HTTPStatusError{
+			StatusCode: r.StatusCode,
+			URL:        url,
+		}
 	}
 
 	return r.Body, nil
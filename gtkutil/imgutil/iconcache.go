@@ -0,0 +1,150 @@
+package imgutil
+
+import (
+	"sync"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/gtkutil"
+)
+
+// maxIconCache is the maximum number of icons kept by IconPaintable. Preloaded
+// icons are kept regardless.
+const maxIconCache = 128
+
+type iconKey struct {
+	name      string
+	size      int
+	scale     int
+	direction gtk.TextDirection
+}
+
+var iconCache struct {
+	sync.Mutex
+	theme  *gtk.IconTheme
+	icons  map[iconKey]*gtk.IconPaintable
+	pinned map[iconKey]struct{}
+}
+
+func init() { OnTrimMemory(trimIconCache) }
+
+// trimIconCache drops all icons that aren't preloaded. They are looked up in
+// the icon theme again when needed.
+func trimIconCache() {
+	iconCache.Lock()
+	defer iconCache.Unlock()
+
+	for k := range iconCache.icons {
+		if _, pinned := iconCache.pinned[k]; !pinned {
+			delete(iconCache.icons, k)
+		}
+	}
+}
+
+// PreloadIcon looks up the icon with the given name and size and keeps it
+// around, so IconPaintable calls with the same name and size never have to
+// look it up in the icon theme again. It is useful for fallback icons that are
+// shown often, such as the ones given to WithFallbackIcon. Preloaded icons are
+// looked up again if the icon theme changes.
+//
+// This function must be called in the main thread.
+func PreloadIcon(name string, size int) {
+	key, theme := newIconKey(name, size)
+
+	iconCache.Lock()
+	defer iconCache.Unlock()
+
+	if iconCache.pinned == nil {
+		iconCache.pinned = make(map[iconKey]struct{})
+	}
+	iconCache.pinned[key] = struct{}{}
+
+	lookupCachedIcon(key, theme)
+}
+
+func cachedIcon(name string, size int) *gtk.IconPaintable {
+	key, theme := newIconKey(name, size)
+
+	iconCache.Lock()
+	defer iconCache.Unlock()
+
+	return lookupCachedIcon(key, theme)
+}
+
+// firstThemedIcon returns the first of names that the current icon theme has,
+// or an empty string if it has none of them.
+func firstThemedIcon(names []string) string {
+	theme := gtk.IconThemeGetForDisplay(gdk.DisplayGetDefault())
+	if theme == nil {
+		panic("imgutil: cannot get IconTheme for default display")
+	}
+
+	for _, name := range names {
+		if name != "" && theme.HasIcon(name) {
+			return name
+		}
+	}
+
+	return ""
+}
+
+func newIconKey(name string, size int) (iconKey, *gtk.IconTheme) {
+	theme := gtk.IconThemeGetForDisplay(gdk.DisplayGetDefault())
+	if theme == nil {
+		panic("imgutil: cannot get IconTheme for default display")
+	}
+
+	return iconKey{
+		name:      name,
+		size:      size,
+		scale:     gtkutil.ScaleFactor(),
+		direction: iconDirection(),
+	}, theme
+}
+
+// iconDirection returns the text direction to look up icons for, so that
+// icons with RTL variants, such as go-next-symbolic, are mirrored in RTL
+// locales.
+func iconDirection() gtk.TextDirection {
+	if gtkutil.IsRTL() {
+		return gtk.TextDirRTL
+	}
+	return gtk.TextDirLTR
+}
+
+// lookupCachedIcon looks up the icon from the cache, or from the theme if it's
+// not cached. iconCache must be locked.
+func lookupCachedIcon(key iconKey, theme *gtk.IconTheme) *gtk.IconPaintable {
+	if iconCache.theme == nil || !iconCache.theme.Eq(theme) {
+		iconCache.theme = theme
+		iconCache.icons = nil
+
+		theme.ConnectChanged(func() {
+			iconCache.Lock()
+			iconCache.icons = nil
+			iconCache.Unlock()
+		})
+	}
+
+	if icon, ok := iconCache.icons[key]; ok {
+		return icon
+	}
+
+	if len(iconCache.icons) >= maxIconCache {
+		// Evict everything but the pinned icons.
+		for k := range iconCache.icons {
+			if _, pinned := iconCache.pinned[k]; !pinned {
+				delete(iconCache.icons, k)
+			}
+		}
+	}
+
+	if iconCache.icons == nil {
+		iconCache.icons = make(map[iconKey]*gtk.IconPaintable)
+	}
+
+	icon := theme.LookupIcon(key.name, nil, key.size, key.scale, key.direction, 0)
+	iconCache.icons[key] = icon
+
+	return icon
+}
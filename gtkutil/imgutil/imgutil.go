@@ -9,6 +9,7 @@ import (
 	"math"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/diamondburned/gotk4/pkg/core/gioutil"
 	"github.com/diamondburned/gotk4/pkg/core/glib"
@@ -30,9 +31,10 @@ const (
 )
 
 type Opts struct {
-	w, h  int
-	setFn ImageSetter
-	done  func(error)
+	w, h    int
+	setFn   ImageSetter
+	done    func(error)
+	timeout time.Duration
 
 	sizer struct {
 		set interface {
@@ -57,6 +59,15 @@ func OptsError(ctx context.Context, err error) {
 	o.Error(err)
 }
 
+// OptsDone calls the done callback inside the context's Opts, if any. Providers
+// call it with a nil error once the image is set, so that callbacks set using
+// WithDoneFn are called on success too. A non-nil error is handled the same as
+// OptsError.
+func OptsDone(ctx context.Context, err error) {
+	o := OptsFromContext(ctx)
+	o.onDone(err)
+}
+
 func (o *Opts) processOpts(funcs []OptFunc) {
 	for _, opt := range funcs {
 		opt(o)
@@ -155,23 +166,26 @@ func WithFallbackIcon(name string) OptFunc {
 }
 
 // IconPaintable gets the icon with the given name and returns the size. Nil is
-// never returned.
-func IconPaintable(name string, w, h int) gdk.Paintabler {
-	if name == "" {
-		name = "image-missing"
-	}
-
+// never returned. Icons are cached until the icon theme changes, so it is cheap
+// to call repeatedly; see PreloadIcon.
+//
+// If the icon theme doesn't have the icon, then each of the fallback names is
+// tried in order, which is useful for icons that are named differently across
+// themes. image-missing is used only if the theme has none of them.
+func IconPaintable(name string, w, h int, fallbacks ...string) gdk.Paintabler {
 	size := w
 	if h < w {
 		size = h
 	}
 
-	theme := gtk.IconThemeGetForDisplay(gdk.DisplayGetDefault())
-	if theme == nil {
-		panic("imgutil: cannot get IconTheme for default display")
+	if len(fallbacks) > 0 {
+		name = firstThemedIcon(append([]string{name}, fallbacks...))
+	}
+	if name == "" {
+		name = "image-missing"
 	}
 
-	return theme.LookupIcon(name, nil, size, gtkutil.ScaleFactor(), gtk.TextDirLTR, 0)
+	return cachedIcon(name, size)
 }
 
 // WithErrorFn adds a callback that is called on an error.
@@ -195,6 +209,24 @@ func WithDoneFn(done func(error)) OptFunc {
 	}
 }
 
+// WithChainedDoneFn is like WithDoneFn, except it can be used even if there
+// is already a done callback, in which case done is called after it. This is
+// useful for wrappers that need to know when loading is done without taking
+// over the caller's own callback.
+func WithChainedDoneFn(done func(error)) OptFunc {
+	return func(o *Opts) {
+		prev := o.done
+		if prev == nil {
+			o.done = done
+			return
+		}
+		o.done = func(err error) {
+			prev(err)
+			done(err)
+		}
+	}
+}
+
 // WithRectRescale is a convenient function around WithRescale for rectangular
 // or circular images.
 func WithRectRescale(size int) OptFunc {
@@ -225,6 +257,26 @@ func WithMaxSize(w, h int) OptFunc {
 	}
 }
 
+// WithTimeout sets the maximum duration of each HTTP request made to fetch the
+// image. It is independent of the HTTP client's own timeout, which still
+// applies, so callers can give different kinds of images different deadlines.
+// Time spent waiting for other downloads to finish is not counted.
+func WithTimeout(d time.Duration) OptFunc {
+	return func(o *Opts) {
+		o.timeout = d
+	}
+}
+
+// requestContext returns a context for a single HTTP request, which is ctx
+// bounded by the timeout set using WithTimeout, if any.
+func requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	o := OptsFromContext(ctx)
+	if o.timeout > 0 {
+		return context.WithTimeout(ctx, o.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
 // WithSizeOverrider overrides the widget's size request to be of the given
 // size.
 func WithSizeOverrider(widget gtk.Widgetter, w, h int) OptFunc {
@@ -297,7 +349,8 @@ func get(ctx context.Context, url string, img ImageSetter, async bool) {
 
 func loadPixbufFromFile(ctx context.Context, path string, img ImageSetter, o Opts) error {
 	// Slow path, since we need to use PixbufLoader to be able to rescale this.
-	if o.w > 0 && o.h > 0 {
+	// Images that are already small enough don't need rescaling.
+	if o.w > 0 && o.h > 0 && !fileFitsSize(path, o.w, o.h) {
 		slog.Debug(
 			"using slow path for image loading since rescaling is needed",
 			"path", path,
@@ -316,34 +369,38 @@ func loadPixbufFromFile(ctx context.Context, path string, img ImageSetter, o Opt
 		return loadPixbufFileManual(ctx, path, img, o)
 	}
 
-	glib.IdleAdd(func() {
-		select {
-		case <-ctx.Done():
-			slog.Error(
-				"cannot set image since the context is done",
-				"err", ctx.Err())
-			return
-		default:
-		}
-
-		if o.sizer.set != nil {
-			o.applySizer(anim.Width(), anim.Height())
+	if img.SetFromAnimation != nil && anim.IsStaticImage() {
+		// gdk-pixbuf may not be able to animate WebP and APNG images.
+		if fallback := fileAnimation(ctx, path, o); fallback != nil {
+			anim = fallback
 		}
+	}
 
+	gtkutil.IdleAddContext(ctx, func() {
 		if img.SetFromAnimation != nil && !anim.IsStaticImage() {
+			if o.sizer.set != nil {
+				o.applySizer(anim.Width(), anim.Height())
+			}
+
 			// Is actually a real animation. Call SetFromAnimation instead
 			// of SetFromPixbuf to signify this.
 			img.SetFromAnimation(anim)
 			return
 		}
 
+		pixbuf := orientedPixbuf(anim.StaticImage())
+
+		if o.sizer.set != nil {
+			o.applySizer(pixbuf.Width(), pixbuf.Height())
+		}
+
 		if img.SetFromPixbuf != nil {
-			img.SetFromPixbuf(anim.StaticImage())
+			img.SetFromPixbuf(pixbuf)
 			return
 		}
 
 		if img.SetFromPaintable != nil {
-			img.SetFromPaintable(gdk.NewTextureForPixbuf(anim.StaticImage()))
+			img.SetFromPaintable(gdk.NewTextureForPixbuf(pixbuf))
 			return
 		}
 
@@ -353,6 +410,23 @@ func loadPixbufFromFile(ctx context.Context, path string, img ImageSetter, o Opt
 	return nil
 }
 
+// fileFitsSize returns true if the image file at path doesn't need to be scaled
+// down to fit within maxW and maxH. Only the file header is read.
+func fileFitsSize(path string, maxW, maxH int) bool {
+	w, h, format := gdkpixbuf.PixbufGetFileInfo(path)
+	if format == nil {
+		return false
+	}
+
+	fits := w <= maxW && h <= maxH
+	if format.Name() == "jpeg" {
+		// The EXIF orientation may swap the width and height.
+		fits = fits && h <= maxW && w <= maxH
+	}
+
+	return fits
+}
+
 func loadPixbufFileManual(ctx context.Context, path string, img ImageSetter, o Opts) error {
 	f, err := os.Open(path)
 	if err != nil {
@@ -363,6 +437,16 @@ func loadPixbufFileManual(ctx context.Context, path string, img ImageSetter, o O
 	return loadPixbuf(ctx, f, img, o)
 }
 
+// LoadFromReader loads the image from r into img using the Opts inside the
+// context. It is useful for Providers that already have the image data. The
+// image is set in the main thread, but this function must not be called in
+// it.
+func LoadFromReader(ctx context.Context, r io.Reader, img ImageSetter) error {
+	o := OptsFromContext(ctx)
+	o.setFn = img
+	return decode(ctx, func() error { return loadPixbuf(ctx, r, img, o) })
+}
+
 var supportedMIMEsData map[string]struct{}
 var supportedMIMEsInit = sync.OnceFunc(func() {
 	formats := gdkpixbuf.PixbufGetFormats()
@@ -390,6 +474,20 @@ func loadPixbuf(ctx context.Context, r io.Reader, img ImageSetter, o Opts) error
 		"module", "imgutil.loadPixbuf")
 	logger.Debug("manually loading image from stream without caching")
 
+	// Keep the data around if the image is animated, in case gdk-pixbuf can't
+	// animate it and it has to be decoded using FFmpeg instead.
+	// The data is only kept up to maxAnimatedData bytes.
+	var animData *cappedBuffer
+	var animW, animH int
+	if peeker, ok := r.(headerPeeker); ok && img.SetFromAnimation != nil {
+		header, _ := peeker.Peek(512)
+		if w, h, ok := animatedSize(mime, header); ok {
+			animData = &cappedBuffer{max: maxAnimatedData}
+			animW, animH = w, h
+			r = io.TeeReader(r, animData)
+		}
+	}
+
 	if !supportedMIME(mime) {
 		logger.Warn("unsupported image type")
 	}
@@ -413,23 +511,50 @@ func loadPixbuf(ctx context.Context, r io.Reader, img ImageSetter, o Opts) error
 	})
 
 	_, err := io.Copy(gioutil.PixbufLoaderWriter(loader), r)
-	if err != nil {
+	if err == nil {
+		err = loader.Close()
+		if err != nil {
+			err = fmt.Errorf("failed to close PixbufLoader: %w", err)
+		}
+	} else {
 		loader.Close()
-		return err
 	}
 
-	if err := loader.Close(); err != nil {
-		return fmt.Errorf("failed to close PixbufLoader: %w", err)
+	var anim *gdkpixbuf.PixbufAnimation
+	if err == nil {
+		anim = loader.Animation()
 	}
 
-	glib.IdleAdd(func() {
-		select {
-		case <-ctx.Done():
-			slog.Error(
-				"cannot set image since the context is done",
-				"err", ctx.Err())
-			return
-		default:
+	if animData != nil && (anim == nil || anim.IsStaticImage()) {
+		// Read the rest of the image in case the loader stopped early, but
+		// only up to the buffering limit.
+		if _, err := io.Copy(io.Discard, io.LimitReader(r, maxAnimatedData)); err != nil {
+			return err
+		}
+	}
+
+	if animData != nil && !animData.over && (anim == nil || anim.IsStaticImage()) {
+		fallback := ffmpegAnimation(ctx, "pipe:0", animData.Bytes(), animW, animH, o)
+		if fallback != nil {
+			anim, err = fallback, nil
+			if o.sizer.set != nil {
+				size = [2]int{anim.Width(), anim.Height()}
+			}
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	gtkutil.IdleAddContext(ctx, func() {
+		var pixbuf *gdkpixbuf.Pixbuf
+		if img.SetFromAnimation == nil || anim.IsStaticImage() {
+			pixbuf = orientedPixbuf(anim.StaticImage())
+			if size != [2]int{} {
+				// The orientation may have swapped the width and height.
+				size = [2]int{pixbuf.Width(), pixbuf.Height()}
+			}
 		}
 
 		if size != [2]int{} {
@@ -444,9 +569,7 @@ func loadPixbuf(ctx context.Context, r io.Reader, img ImageSetter, o Opts) error
 			o.sizer.set.SetSizeRequest(w, h)
 		}
 
-		anim := loader.Animation()
-
-		if img.SetFromAnimation != nil && !anim.IsStaticImage() {
+		if pixbuf == nil {
 			// Is actually a real animation. Call SetFromAnimation instead
 			// of SetFromPixbuf to signify this.
 			img.SetFromAnimation(anim)
@@ -454,12 +577,12 @@ func loadPixbuf(ctx context.Context, r io.Reader, img ImageSetter, o Opts) error
 		}
 
 		if img.SetFromPixbuf != nil {
-			img.SetFromPixbuf(anim.StaticImage())
+			img.SetFromPixbuf(pixbuf)
 			return
 		}
 
 		if img.SetFromPaintable != nil {
-			img.SetFromPaintable(gdk.NewTextureForPixbuf(anim.StaticImage()))
+			img.SetFromPaintable(gdk.NewTextureForPixbuf(pixbuf))
 			return
 		}
 
@@ -472,24 +595,11 @@ func loadPixbuf(ctx context.Context, r io.Reader, img ImageSetter, o Opts) error
 	return nil
 }
 
-func loadStdImage(ctx context.Context, decoder func() (image.Image, error), setter ImageSetter, o Opts) error {
-	img, err := decoder()
-	if err != nil {
-		return errors.Wrap(err, "cannot decode image")
-	}
-
+// setStdImage sets the given image into the setter in the main thread.
+func setStdImage(ctx context.Context, img image.Image, setter ImageSetter, o Opts) error {
 	pixbuf := gdkpixbuf.NewPixbufFromImage(img)
 
-	glib.IdleAdd(func() {
-		select {
-		case <-ctx.Done():
-			slog.Error(
-				"cannot set image since the context is done",
-				"err", ctx.Err())
-			return
-		default:
-		}
-
+	gtkutil.IdleAddContext(ctx, func() {
 		if o.sizer.set != nil {
 			o.applySizer(pixbuf.Width(), pixbuf.Height())
 		}
@@ -0,0 +1,153 @@
+package imgutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/gdkpixbuf/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotkit/app"
+	"golang.org/x/sync/semaphore"
+)
+
+func writeTestPNG(b *testing.B, w, h int) string {
+	path := filepath.Join(b.TempDir(), "image.png")
+
+	f, err := os.Create(path)
+	if err != nil {
+		b.Fatal("cannot create PNG:", err)
+	}
+	defer f.Close()
+
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		b.Fatal("cannot encode PNG:", err)
+	}
+
+	return path
+}
+
+// runMainLoop iterates the default main context in the background until the
+// benchmark ends, so that the loaded images are actually set.
+func runMainLoop(b *testing.B) {
+	main := glib.MainContextDefault()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				main.Iteration(true)
+			}
+		}
+	}()
+
+	b.Cleanup(func() {
+		close(done)
+		// Wake the loop up so that it sees done.
+		glib.IdleAdd(func() {})
+		<-stopped
+	})
+}
+
+// BenchmarkFetchConcurrent fetches and decodes a burst of images from a server
+// that is slow to respond. It compares decoding using the decoder limiter
+// against decoding every finished download at once, which leaves less CPU for
+// the downloads that are still in flight.
+func BenchmarkFetchConcurrent(b *testing.B) {
+	const maxW, maxH = 512, 512
+
+	var data bytes.Buffer
+	if err := png.Encode(&data, image.NewRGBA(image.Rect(0, 0, 1024, 1024))); err != nil {
+		b.Fatal("cannot encode PNG:", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(data.Bytes())
+	}))
+	b.Cleanup(srv.Close)
+
+	a := app.New(context.Background(), "com.example.imgutil", "imgutil")
+	a.SetCacheDir(b.TempDir())
+	ctx := app.WithApplication(context.Background(), a)
+
+	runMainLoop(b)
+
+	setter := ImageSetter{SetFromPixbuf: func(*gdkpixbuf.Pixbuf) {}}
+	// n makes every URL unique, so that nothing is loaded from the cache.
+	var n atomic.Int64
+
+	bench := func(b *testing.B) {
+		b.SetParallelism(8)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				url := fmt.Sprintf("%s/%d.png", srv.URL, n.Add(1))
+				if err := fetchImage(ctx, url, setter, Opts{w: maxW, h: maxH}); err != nil {
+					b.Error("cannot fetch image:", err)
+					return
+				}
+			}
+		})
+	}
+
+	b.Run("limited", bench)
+
+	b.Run("unbounded", func(b *testing.B) {
+		limited := decoders
+		decoders = semaphore.NewWeighted(math.MaxInt64)
+		defer func() { decoders = limited }()
+
+		bench(b)
+	})
+}
+
+// BenchmarkLoadPixbufFromFile compares loading an image that is already small
+// enough using loadPixbufFromFile, which takes the fast path, against loading
+// the same image using loadPixbufFileManual, which is the PixbufLoader path
+// that loadPixbufFromFile takes when the image needs rescaling.
+func BenchmarkLoadPixbufFromFile(b *testing.B) {
+	const maxW, maxH = 512, 512
+	path := writeTestPNG(b, 256, 256)
+	if !fileFitsSize(path, maxW, maxH) {
+		b.Fatal("image unexpectedly needs rescaling")
+	}
+
+	runMainLoop(b)
+
+	type loadFunc func(ctx context.Context, path string, img ImageSetter, o Opts) error
+	bench := func(load loadFunc) func(b *testing.B) {
+		return func(b *testing.B) {
+			set := make(chan struct{}, 1)
+			setter := ImageSetter{
+				SetFromPixbuf: func(*gdkpixbuf.Pixbuf) { set <- struct{}{} },
+			}
+
+			for i := 0; i < b.N; i++ {
+				if err := load(context.Background(), path, setter, Opts{w: maxW, h: maxH}); err != nil {
+					b.Fatal("cannot load image:", err)
+				}
+				// Wait for the main loop to set the image.
+				<-set
+			}
+		}
+	}
+
+	b.Run("fast", bench(loadPixbufFromFile))
+	b.Run("slow", bench(loadPixbufFileManual))
+}
@@ -0,0 +1,159 @@
+// Package imgutiltest provides in-memory image sources for testing components
+// that fetch images through imgutil without touching the network or FFmpeg.
+package imgutiltest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/diamondburned/gotk4/pkg/gdkpixbuf/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotkit/gtkutil/httputil"
+	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+)
+
+// Transport is an http.RoundTripper that serves canned responses. It maps
+// full URLs to their response bodies. URLs that are not in the map get a 404
+// response.
+type Transport map[string][]byte
+
+var _ http.RoundTripper = Transport(nil)
+
+// RoundTrip implements http.RoundTripper.
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	body, ok := t[req.URL.String()]
+	if !ok {
+		return &http.Response{
+			Status:     "404 Not Found",
+			StatusCode: http.StatusNotFound,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     make(http.Header),
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	}
+
+	header := make(http.Header)
+	header.Set("Content-Type", http.DetectContentType(body))
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// Client returns an HTTP client that serves the given responses using
+// Transport.
+func Client(responses map[string][]byte) *http.Client {
+	return &http.Client{Transport: Transport(responses)}
+}
+
+// WithResponses returns a context that makes imgutil's HTTP functions serve
+// the given responses instead of fetching from the network. It is a
+// convenient function around httputil.WithClient.
+func WithResponses(ctx context.Context, responses map[string][]byte) context.Context {
+	return httputil.WithClient(ctx, Client(responses))
+}
+
+// Provider is an imgutil.Provider that serves images from memory. It maps full
+// URLs to their image data. Unlike Transport, nothing is cached on the disk.
+// Like the built-in providers, it calls the done callback of the Opts once the
+// image is set or fails to load.
+type Provider struct {
+	images  map[string][]byte
+	schemes []string
+}
+
+var _ imgutil.Provider = (*Provider)(nil)
+
+// NewProvider creates a new Provider serving the given images. If no schemes
+// are given, then the schemes of the URLs in images are used.
+func NewProvider(images map[string][]byte, schemes ...string) *Provider {
+	if len(schemes) == 0 {
+		seen := make(map[string]struct{})
+		for u := range images {
+			if u, err := url.Parse(u); err == nil {
+				seen[u.Scheme] = struct{}{}
+			}
+		}
+		for scheme := range seen {
+			schemes = append(schemes, scheme)
+		}
+		sort.Strings(schemes)
+	}
+
+	return &Provider{
+		images:  images,
+		schemes: schemes,
+	}
+}
+
+// Schemes implements imgutil.Provider.
+func (p *Provider) Schemes() []string {
+	return p.schemes
+}
+
+// Do implements imgutil.Provider.
+func (p *Provider) Do(ctx context.Context, url *url.URL, img imgutil.ImageSetter) {
+	data, ok := p.images[url.String()]
+	if !ok {
+		imgutil.OptsError(ctx, fmt.Errorf("imgutiltest: no image for %q", url))
+		return
+	}
+
+	go func() {
+		imgutil.OptsDone(ctx, imgutil.LoadFromReader(ctx, bytes.NewReader(data), img))
+	}()
+}
+
+// Do invokes the provider with the given URL and waits for it to be done by
+// iterating the default main context. The image that the provider set, if any,
+// is returned along with the error given to the done callback. If the context
+// expires before the provider is done, then its error is returned instead.
+//
+// Do must be called on the main thread, and the context must not already have
+// a done callback.
+func Do(ctx context.Context, p imgutil.Provider, url string) (*gdkpixbuf.Pixbuf, error) {
+	var pixbuf *gdkpixbuf.Pixbuf
+	var done bool
+	var err error
+
+	imgutil.DoProviderURL(
+		imgutil.WithOpts(ctx, imgutil.WithDoneFn(func(doneErr error) {
+			done = true
+			err = doneErr
+		})),
+		p, url,
+		imgutil.ImageSetter{
+			SetFromPixbuf: func(set *gdkpixbuf.Pixbuf) { pixbuf = set },
+		},
+	)
+
+	main := glib.MainContextDefault()
+	for !done {
+		if ctx.Err() != nil {
+			return pixbuf, ctx.Err()
+		}
+		main.Iteration(false)
+	}
+
+	return pixbuf, err
+}
@@ -0,0 +1,52 @@
+package imgutiltest
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func testPNG(t *testing.T, w, h int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal("cannot encode PNG:", err)
+	}
+	return buf.Bytes()
+}
+
+func TestProvider(t *testing.T) {
+	const found = "test://found.png"
+	prov := NewProvider(map[string][]byte{found: testPNG(t, 4, 2)})
+
+	t.Run("found", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		pixbuf, err := Do(ctx, prov, found)
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+		if pixbuf == nil {
+			t.Fatal("no image was set")
+		}
+		if w, h := pixbuf.Width(), pixbuf.Height(); w != 4 || h != 2 {
+			t.Errorf("expected size 4x2, got %dx%d", w, h)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		pixbuf, err := Do(ctx, prov, "test://missing.png")
+		if err == nil || ctx.Err() != nil {
+			t.Fatal("expected the provider to fail, got", err)
+		}
+		if pixbuf != nil {
+			t.Error("unexpected image set")
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package imgutil
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+var trimHooks struct {
+	sync.Mutex
+	funcs []func()
+}
+
+// OnTrimMemory registers f to be called every time TrimMemory is called.
+// Packages that keep their own in-memory image caches, such as onlineimage,
+// use this to have them dropped along with imgutil's.
+func OnTrimMemory(f func()) {
+	trimHooks.Lock()
+	trimHooks.funcs = append(trimHooks.funcs, f)
+	trimHooks.Unlock()
+}
+
+// TrimMemory calls the hooks registered using OnTrimMemory so that they drop
+// their in-memory image caches, which includes imgutil's own cache of icons
+// used by IconPaintable. Images cached on disk are not touched, and
+// neither are the URLs known to be invalid, since forgetting them would only
+// cause them to be fetched again. It is meant to be called when the system is
+// running low on memory.
+//
+// This function can be called from any thread.
+func TrimMemory() {
+	trimHooks.Lock()
+	funcs := trimHooks.funcs
+	trimHooks.Unlock()
+
+	for _, f := range funcs {
+		f()
+	}
+}
+
+// TrimMemoryOnLowMemory binds TrimMemory to GIO's MemoryMonitor, so that it is
+// called whenever the system warns that it is running low on memory. It must
+// be called on the main thread. The returned function unbinds it.
+func TrimMemoryOnLowMemory() (unbind func()) {
+	monitor := gio.MemoryMonitorDupDefault()
+	if monitor == nil {
+		slog.Warn(
+			"no MemoryMonitor available, not trimming on low memory",
+			"module", "imgutil")
+		return func() {}
+	}
+
+	handle := monitor.ConnectLowMemoryWarning(func(level gio.MemoryMonitorWarningLevel) {
+		slog.Debug(
+			"low memory warning received, trimming image caches",
+			"module", "imgutil",
+			"level", int(level))
+		TrimMemory()
+	})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			glib.IdleAdd(func() { monitor.HandlerDisconnect(handle) })
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package imgutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/gtkutil/httputil"
+	"github.com/diamondburned/gotkit/gtkutil/mediautil"
+	"github.com/pkg/errors"
+)
+
+// maxMIMECache is the maximum number of MIME types that a MIMEProvider
+// remembers.
+const maxMIMECache = 1024
+
+type mimeProvider struct {
+	providers map[string]Provider
+	// client is the client to get MIME types over HTTP with, or nil to use the
+	// one in the context.
+	client *http.Client
+
+	mu sync.Mutex
+	// mimes maps hashed URLs to their MIME types.
+	mimes map[string]string
+}
+
+// MIMEProvider creates a Provider that delegates to one of the given providers
+// based on the MIME type of the resource. This is useful for routing videos to
+// FFmpegProvider while images go to HTTPProvider, for example:
+//
+//	imgutil.MIMEProvider(map[string]imgutil.Provider{
+//		"":       imgutil.HTTPProvider,
+//		"video/": imgutil.FFmpegProvider,
+//	})
+//
+// The keys are either full MIME types like "image/gif" or type prefixes ending
+// with a slash like "video/". A full MIME type takes precedence over a prefix.
+// The provider with the empty key is used for everything else, including when
+// the MIME type cannot be determined.
+//
+// For HTTP and HTTPS URLs, the MIME type is taken from the Content-Type of a
+// HEAD request, or sniffed from the first bytes of the resource if the server
+// doesn't give a useful one. The request is made using the client of the
+// providers, such as one given to NewHTTPProvider, if they have one. Images
+// that are already in the cache and files are sniffed instead. The MIME types
+// of recently used URLs are remembered.
+func MIMEProvider(providers map[string]Provider) Provider {
+	p := &mimeProvider{providers: providers}
+	p.client = p.httpClient()
+	return p
+}
+
+// Schemes implements Provider. It returns the schemes of all providers.
+func (p *mimeProvider) Schemes() []string {
+	var schemes []string
+	seen := make(map[string]struct{})
+	for _, prov := range p.providers {
+		for _, scheme := range prov.Schemes() {
+			if _, ok := seen[scheme]; !ok {
+				seen[scheme] = struct{}{}
+				schemes = append(schemes, scheme)
+			}
+		}
+	}
+	return schemes
+}
+
+// Do implements Provider.
+func (p *mimeProvider) Do(ctx context.Context, url *url.URL, img ImageSetter) {
+	key := httputil.HashURL(url.String())
+
+	p.mu.Lock()
+	mime, ok := p.mimes[key]
+	p.mu.Unlock()
+
+	if ok {
+		p.doMIME(ctx, url, img, mime)
+		return
+	}
+
+	go func() {
+		// Only use our client for getting the MIME type, since the chosen
+		// provider may have its own.
+		mimeCtx := ctx
+		if p.client != nil {
+			mimeCtx = httputil.WithClient(ctx, p.client)
+		}
+
+		mime, err := resourceMIME(mimeCtx, url)
+		if err != nil {
+			if ctx.Err() != nil {
+				OptsError(ctx, ctx.Err())
+				return
+			}
+			// Let the default provider try it, but don't remember the
+			// failure, since it may only be temporary.
+			mime = ""
+		} else {
+			p.storeMIME(key, mime)
+		}
+
+		p.doMIME(ctx, url, img, mime)
+	}()
+}
+
+func (p *mimeProvider) storeMIME(key, mime string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.mimes) >= maxMIMECache {
+		p.mimes = nil
+	}
+	if p.mimes == nil {
+		p.mimes = make(map[string]string)
+	}
+	p.mimes[key] = mime
+}
+
+// httpClient returns the client of the first provider that has its own. The
+// default provider is checked first.
+func (p *mimeProvider) httpClient() *http.Client {
+	if client := providerClient(p.providers[""]); client != nil {
+		return client
+	}
+
+	keys := make([]string, 0, len(p.providers))
+	for key := range p.providers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if client := providerClient(p.providers[key]); client != nil {
+			return client
+		}
+	}
+
+	return nil
+}
+
+// providerClient returns the HTTP client that the given provider fetches with,
+// or nil if it uses the one in the context.
+func providerClient(prov Provider) *http.Client {
+	switch prov := prov.(type) {
+	case httpProvider:
+		return prov.client
+	case fallbackProvider:
+		for _, sub := range prov {
+			if client := providerClient(sub); client != nil {
+				return client
+			}
+		}
+	case *mimeProvider:
+		return prov.client
+	}
+	return nil
+}
+
+func (p *mimeProvider) doMIME(ctx context.Context, url *url.URL, img ImageSetter, mime string) {
+	prov := p.provider(mime)
+	if prov == nil {
+		OptsError(ctx, fmt.Errorf("no provider for MIME type %q", mime))
+		return
+	}
+
+	prov.Do(ctx, url, img)
+}
+
+func (p *mimeProvider) provider(mime string) Provider {
+	if mime != "" {
+		if prov, ok := p.providers[mime]; ok {
+			return prov
+		}
+		if typ, _, ok := strings.Cut(mime, "/"); ok {
+			if prov, ok := p.providers[typ+"/"]; ok {
+				return prov
+			}
+		}
+	}
+	return p.providers[""]
+}
+
+// resourceMIME returns the MIME type of the resource at the given URL without
+// its parameters.
+func resourceMIME(ctx context.Context, url *url.URL) (string, error) {
+	switch url.Scheme {
+	case "http", "https":
+		// Sniff the cached image if there is one, so that the network isn't
+		// touched just to load an image that's already on the disk.
+		cacheDst := urlPath(app.FromContext(ctx).CachePath("img2"), url.String())
+		if path, ok := cachedPath(ctx, cacheDst); ok {
+			if mime, err := fileMIME(path); err == nil {
+				return mime, nil
+			}
+		}
+		return httpMIME(ctx, url.String())
+	case "file":
+		return fileMIME(url.Host + url.Path)
+	default:
+		return "", fmt.Errorf("cannot get MIME type for scheme %q", url.Scheme)
+	}
+}
+
+func fileMIME(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return mediautil.MIME(f), nil
+}
+
+func httpMIME(ctx context.Context, url string) (string, error) {
+	if IsOfflineMode() {
+		return "", ErrOffline
+	}
+
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+
+	client := httputil.FromContext(ctx, defaultClient)
+
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create request %q", url)
+	}
+
+	r, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	r.Body.Close()
+
+	if r.StatusCode >= 200 && r.StatusCode <= 299 {
+		if mime := parseMIME(r.Header.Get("Content-Type")); mime != "" {
+			return mime, nil
+		}
+	}
+
+	// Some servers don't allow HEAD requests or don't give a useful
+	// Content-Type, so sniff the first bytes instead.
+	req, err = http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create request %q", url)
+	}
+	req.Header.Set("Range", "bytes=0-511")
+
+	r, err = client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode < 200 || r.StatusCode > 299 {
+		return "", fmt.Errorf("unexpected status code %d getting %q", r.StatusCode, url)
+	}
+
+	_, mime := mediautil.MIMEBuffered(io.LimitReader(r.Body, 512))
+	return parseMIME(mime), nil
+}
+
+// parseMIME returns the MIME type without its parameters. An empty string is
+// returned if the type is invalid or too generic to be useful.
+func parseMIME(contentType string) string {
+	typ, _, err := mime.ParseMediaType(contentType)
+	if err != nil || typ == "application/octet-stream" {
+		return ""
+	}
+	return typ
+}
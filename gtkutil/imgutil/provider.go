@@ -3,12 +3,19 @@ package imgutil
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"net/url"
+	"path"
 	"sort"
+	"strings"
 
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
 	"github.com/diamondburned/gotk4/pkg/gdk/v4"
 	"github.com/diamondburned/gotk4/pkg/gdkpixbuf/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil/httputil"
+	"github.com/pkg/errors"
 )
 
 // Provider describes a universal resource provider.
@@ -40,6 +47,28 @@ func ImageSetterFromPicture(picture *gtk.Picture) ImageSetter {
 	}
 }
 
+// ImageSetterFromAvatar returns an ImageSetter for an adw.Avatar. The image is
+// set as the avatar's custom image.
+func ImageSetterFromAvatar(avatar *adw.Avatar) ImageSetter {
+	return ImageSetterFromPaintableFunc(avatar.SetCustomImage)
+}
+
+// ImageSetterFromPaintableFunc returns an ImageSetter for widgets that can only
+// show a gdk.Paintable, such as adw.Avatar. Pixbufs are converted to textures
+// before being given to set, and a nil pixbuf is given as a nil paintable.
+func ImageSetterFromPaintableFunc(set func(gdk.Paintabler)) ImageSetter {
+	return ImageSetter{
+		SetFromPixbuf: func(p *gdkpixbuf.Pixbuf) {
+			if p != nil {
+				set(gdk.NewTextureForPixbuf(p))
+			} else {
+				set(nil)
+			}
+		},
+		SetFromPaintable: set,
+	}
+}
+
 // DoProviderURL invokes a Provider with the given URI string (instead of a
 // *url.URL instance).
 func DoProviderURL(ctx context.Context, p Provider, uri string, img ImageSetter) {
@@ -72,6 +101,19 @@ func ParseURLSize(url *url.URL) (w, h int) {
 	return
 }
 
+// ImageName returns the name of the image at the given URL, which is the last
+// element of its path without the extension. If the URL has no such element,
+// then a localized "Image" is returned. It is useful as a window title or as
+// the default name to save the image as.
+func ImageName(urlStr string) string {
+	if u, err := url.Parse(urlStr); err == nil {
+		if base := path.Base(u.Path); base != "." && base != "/" {
+			return strings.TrimSuffix(base, path.Ext(base))
+		}
+	}
+	return locale.Get("Image")
+}
+
 // Providers holds multiple providers. A Providers instance is also a Provider
 // in itself.
 type Providers map[string]Provider
@@ -90,6 +132,35 @@ func NewProviders(providers ...Provider) Providers {
 	return m
 }
 
+// With returns a copy of the Providers with the given providers added. Like
+// NewProviders, providers that are put last override the schemes of existing
+// providers. The original Providers is not modified.
+func (p Providers) With(providers ...Provider) Providers {
+	m := make(Providers, len(p)+len(providers))
+	for scheme, prov := range p {
+		m[scheme] = prov
+	}
+	for _, prov := range providers {
+		for _, scheme := range prov.Schemes() {
+			m[scheme] = prov
+		}
+	}
+	return m
+}
+
+// Without returns a copy of the Providers without the given schemes. The
+// original Providers is not modified.
+func (p Providers) Without(schemes ...string) Providers {
+	m := make(Providers, len(p))
+	for scheme, prov := range p {
+		m[scheme] = prov
+	}
+	for _, scheme := range schemes {
+		delete(m, scheme)
+	}
+	return m
+}
+
 // Schemes returns all schemes within the Providers. It exists only to implement
 // Provider and generally shouldn't be used. The returned list is always sorted.
 func (p Providers) Schemes() []string {
@@ -112,12 +183,99 @@ func (p Providers) Do(ctx context.Context, url *url.URL, img ImageSetter) {
 	provider.Do(ctx, url, img)
 }
 
-type httpProvider struct{}
+type fallbackProvider []Provider
+
+// FallbackProvider creates a Provider that tries each of the given providers
+// in order until one succeeds. A provider is considered to have failed if it
+// reports an error through the Opts, such as with OptsError; the next provider
+// that supports the URL's scheme is then tried. Providers must report success
+// using OptsDone, which all built-in providers do. Only the error of the last
+// provider is reported.
+//
+// Unlike Providers, multiple providers may handle the same scheme. This is
+// useful for trying a fast thumbnail service before falling back to FFmpeg,
+// for example:
+//
+//	imgutil.NewProviders(
+//		imgutil.FallbackProvider(thumbnailProvider, imgutil.FFmpegProvider),
+//	)
+func FallbackProvider(providers ...Provider) Provider {
+	return fallbackProvider(providers)
+}
+
+// Schemes implements Provider. It returns the schemes of all providers.
+func (p fallbackProvider) Schemes() []string {
+	var schemes []string
+	seen := make(map[string]struct{})
+	for _, prov := range p {
+		for _, scheme := range prov.Schemes() {
+			if _, ok := seen[scheme]; !ok {
+				seen[scheme] = struct{}{}
+				schemes = append(schemes, scheme)
+			}
+		}
+	}
+	return schemes
+}
+
+// Do implements Provider.
+func (p fallbackProvider) Do(ctx context.Context, url *url.URL, img ImageSetter) {
+	var providers []Provider
+	for _, prov := range p {
+		for _, scheme := range prov.Schemes() {
+			if scheme == url.Scheme {
+				providers = append(providers, prov)
+				break
+			}
+		}
+	}
+
+	doFallback(ctx, url, img, providers)
+}
+
+func doFallback(ctx context.Context, url *url.URL, img ImageSetter, providers []Provider) {
+	switch len(providers) {
+	case 0:
+		OptsError(ctx, fmt.Errorf("unknown scheme %q", url.Scheme))
+		return
+	case 1:
+		// Last provider, so let it report to the caller's Opts directly.
+		providers[0].Do(ctx, url, img)
+		return
+	}
+
+	o := OptsFromContext(ctx)
+	done := o.done
+
+	o.done = func(err error) {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			doFallback(ctx, url, img, providers[1:])
+			return
+		}
+		if done != nil {
+			done(err)
+		}
+	}
+
+	providers[0].Do(context.WithValue(ctx, optsKey, o), url, img)
+}
+
+type httpProvider struct {
+	client *http.Client
+}
 
 // HTTPProvider is the universal resource provider that handles HTTP and HTTPS
 // schemes (http:// and https://).
 var HTTPProvider Provider = httpProvider{}
 
+// NewHTTPProvider creates a new provider like HTTPProvider that fetches images
+// using the given client instead of the one in the context. It is useful for
+// giving a provider its own transport, such as one that authenticates requests
+// to a specific host, without affecting other providers.
+func NewHTTPProvider(client *http.Client) Provider {
+	return httpProvider{client: client}
+}
+
 // Schemes implements Provider.
 func (p httpProvider) Schemes() []string {
 	return []string{"http", "https"}
@@ -125,6 +283,9 @@ func (p httpProvider) Schemes() []string {
 
 // Do implements Provider.
 func (p httpProvider) Do(ctx context.Context, url *url.URL, img ImageSetter) {
+	if p.client != nil {
+		ctx = httputil.WithClient(ctx, p.client)
+	}
 	AsyncGET(ctx, url.String(), img)
 }
 
@@ -142,8 +303,7 @@ func (p fileProvider) Schemes() []string {
 func (p fileProvider) Do(ctx context.Context, url *url.URL, img ImageSetter) {
 	go func() {
 		o := OptsFromContext(ctx)
-		if err := loadPixbufFromFile(ctx, url.Host+url.Path, img, o); err != nil {
-			o.Error(err)
-		}
+		path := url.Host + url.Path
+		o.onDone(decode(ctx, func() error { return loadPixbufFromFile(ctx, path, img, o) }))
 	}()
 }
@@ -0,0 +1,63 @@
+package imgutil_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+	"github.com/diamondburned/gotkit/gtkutil/imgutil/imgutiltest"
+)
+
+func TestFallbackProvider(t *testing.T) {
+	const url = "test://image.png"
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 2))); err != nil {
+		t.Fatal("cannot encode PNG:", err)
+	}
+
+	failing := imgutiltest.NewProvider(nil, "test")
+	working := imgutiltest.NewProvider(map[string][]byte{url: buf.Bytes()})
+
+	tests := []struct {
+		name      string
+		providers []imgutil.Provider
+		ok        bool
+	}{
+		{"fallback", []imgutil.Provider{failing, working}, true},
+		{"first", []imgutil.Provider{working, failing}, true},
+		{"all failing", []imgutil.Provider{failing, failing}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			pixbuf, err := imgutiltest.Do(ctx, imgutil.FallbackProvider(test.providers...), url)
+			if ctx.Err() != nil {
+				t.Fatal("provider never reported being done")
+			}
+
+			if test.ok {
+				if err != nil {
+					t.Fatal("unexpected error:", err)
+				}
+				if pixbuf == nil {
+					t.Fatal("no image was set")
+				}
+			} else {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				if pixbuf != nil {
+					t.Error("unexpected image set")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package gtkutil
+
+import (
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app/locale"
+)
+
+// MenuBuilder builds a gio.Menu using chained method calls. It is useful for
+// declaratively constructing context menus, e.g. inside a BindRightClickAt
+// callback:
+//
+//	gtkutil.BindRightClickAt(w, func(x, y float64) {
+//	    gtkutil.NewMenuBuilder().
+//	        Item("Copy", "message.copy").
+//	        Item("Reply", "message.reply").
+//	        Separator().
+//	        Submenu("React", func(b *gtkutil.MenuBuilder) {
+//	            b.ItemTarget("Like", "message.react", glib.NewVariantString("👍"))
+//	        }).
+//	        PopupAt(w, gtk.PosBottom, x, y)
+//	})
+type MenuBuilder struct {
+	menu *gio.Menu
+	// section is the menu that items are currently appended to. It is either
+	// menu or the last section appended to it.
+	section *gio.Menu
+}
+
+// NewMenuBuilder creates a new empty MenuBuilder.
+func NewMenuBuilder() *MenuBuilder {
+	menu := gio.NewMenu()
+	return &MenuBuilder{
+		menu:    menu,
+		section: menu,
+	}
+}
+
+// Item appends an item that activates the given action when clicked. If action
+// is empty, then the item is disabled.
+func (b *MenuBuilder) Item(label locale.Localized, action string) *MenuBuilder {
+	b.section.AppendItem(gio.NewMenuItem(label.String(), action))
+	return b
+}
+
+// ItemTarget is like Item, except the action is activated with the given target
+// as its parameter.
+func (b *MenuBuilder) ItemTarget(label locale.Localized, action string, target *glib.Variant) *MenuBuilder {
+	item := gio.NewMenuItem(label.String(), "")
+	item.SetActionAndTargetValue(action, target)
+	b.section.AppendItem(item)
+	return b
+}
+
+// ItemIcon is like Item, except the item also shows the icon with the given
+// name.
+func (b *MenuBuilder) ItemIcon(label locale.Localized, action, icon string) *MenuBuilder {
+	item := gio.NewMenuItem(label.String(), action)
+	item.SetIcon(gio.NewThemedIcon(icon))
+	b.section.AppendItem(item)
+	return b
+}
+
+// Section starts a new section with the given label. All items appended after
+// this are placed inside the new section, which is separated from the previous
+// items.
+func (b *MenuBuilder) Section(label locale.Localized) *MenuBuilder {
+	b.section = gio.NewMenu()
+	b.menu.AppendSection(label.String(), b.section)
+	return b
+}
+
+// Separator starts a new section without a label. It is the same as calling
+// Section with an empty label.
+func (b *MenuBuilder) Separator() *MenuBuilder {
+	return b.Section("")
+}
+
+// Submenu appends a submenu with the given label. The submenu is built by f
+// using its own MenuBuilder.
+func (b *MenuBuilder) Submenu(label locale.Localized, f func(*MenuBuilder)) *MenuBuilder {
+	sub := NewMenuBuilder()
+	f(sub)
+	b.section.AppendSubmenu(label.String(), sub.menu)
+	return b
+}
+
+// Menu returns the built menu.
+func (b *MenuBuilder) Menu() *gio.Menu {
+	return b.menu
+}
+
+// Popover creates a new gtk.PopoverMenu of the built menu that is attached to
+// w. The popover isn't shown.
+func (b *MenuBuilder) Popover(w gtk.Widgetter, pos gtk.PositionType) *gtk.PopoverMenu {
+	popover := gtk.NewPopoverMenuFromModel(b.menu)
+	popover.SetMnemonicsVisible(true)
+	popover.SetSizeRequest(PopoverWidth, -1)
+	popover.SetPosition(pos)
+	popover.SetParent(w)
+	return popover
+}
+
+// PopupAt shows the built menu as a popover attached to w, pointing at the
+// given coordinates relative to w. The popover is destroyed once it's closed.
+func (b *MenuBuilder) PopupAt(w gtk.Widgetter, pos gtk.PositionType, x, y float64) *gtk.PopoverMenu {
+	at := gdk.NewRectangle(int(x), int(y), 0, 0)
+
+	popover := b.Popover(w, pos)
+	popover.SetPointingTo(&at)
+	PopupFinally(popover)
+	return popover
+}
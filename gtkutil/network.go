@@ -0,0 +1,62 @@
+package gtkutil
+
+import (
+	"sync"
+
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+// IsOnline returns true if the system has full network connectivity, as
+// reported by GIO's NetworkMonitor. If no monitor is available, then the system
+// is assumed to be online. It must be called on the main thread.
+func IsOnline() bool {
+	monitor := gio.NetworkMonitorGetDefault()
+	if monitor == nil {
+		return true
+	}
+	return isOnline(monitor)
+}
+
+func isOnline(monitor *gio.NetworkMonitor) bool {
+	return monitor.NetworkAvailable() &&
+		monitor.Connectivity() == gio.NetworkConnectivityFull
+}
+
+// OnNetworkChange calls f on the main loop whenever the system goes online or
+// offline, as reported by IsOnline. f is not called for network changes that
+// don't change whether the system is online, such as switching networks. It
+// must be called on the main thread. The returned function unsubscribes f and
+// can be called from any thread.
+func OnNetworkChange(f func(online bool)) (unsub func()) {
+	monitor := gio.NetworkMonitorGetDefault()
+	if monitor == nil {
+		return func() {}
+	}
+
+	online := isOnline(monitor)
+	check := func() {
+		if now := isOnline(monitor); now != online {
+			online = now
+			f(online)
+		}
+	}
+
+	// Connectivity may change without the network itself changing, such as
+	// when a captive portal is passed.
+	handles := []glib.SignalHandle{
+		monitor.ConnectNetworkChanged(func(bool) { check() }),
+		monitor.NotifyProperty("connectivity", check),
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			glib.IdleAdd(func() {
+				for _, handle := range handles {
+					monitor.HandlerDisconnect(handle)
+				}
+			})
+		})
+	}
+}
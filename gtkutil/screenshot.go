@@ -0,0 +1,52 @@
+package gtkutil
+
+import (
+	"errors"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gdkpixbuf/v2"
+	"github.com/diamondburned/gotk4/pkg/graphene"
+	"github.com/diamondburned/gotk4/pkg/gsk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// SnapshotWidget renders the current appearance of w into a pixbuf, e.g. for
+// attaching a screenshot to a bug report. The pixbuf has the widget's size in
+// logical pixels. An error is returned if w isn't allocated yet or isn't inside
+// a realized window. Use imgutil.EncodePNG to save the pixbuf.
+func SnapshotWidget(w gtk.Widgetter) (*gdkpixbuf.Pixbuf, error) {
+	base := gtk.BaseWidget(w)
+
+	width, height := base.Width(), base.Height()
+	if width <= 0 || height <= 0 {
+		return nil, errors.New("widget is not allocated")
+	}
+
+	native := base.Native()
+	if native == nil || native.Renderer() == nil {
+		return nil, errors.New("widget is not inside a realized window")
+	}
+
+	snapshot := gtk.NewSnapshot()
+	gtk.NewWidgetPaintable(w).Snapshot(snapshot, float64(width), float64(height))
+
+	node := snapshot.ToNode()
+	if node == nil {
+		return nil, errors.New("widget rendered nothing")
+	}
+
+	viewport := graphene.RectAlloc().Init(0, 0, float32(width), float32(height))
+
+	renderer := gsk.BaseRenderer(native.Renderer())
+	texture := renderer.RenderTexture(node, viewport)
+	if texture == nil {
+		return nil, errors.New("cannot render widget into a texture")
+	}
+
+	pixbuf := gdk.PixbufGetFromTexture(texture)
+	if pixbuf == nil {
+		return nil, errors.New("cannot convert widget texture into a pixbuf")
+	}
+
+	return pixbuf, nil
+}
@@ -0,0 +1,275 @@
+package gtkutil
+
+import (
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app/locale"
+)
+
+// ShortcutInfo describes a keyboard shortcut that is bound and described using
+// DescribeShortcuts.
+type ShortcutInfo struct {
+	// Section is the name of the part of the application that the shortcut
+	// belongs to, such as "Image Viewer".
+	Section locale.Localized
+	// Title describes what the shortcut does.
+	Title locale.Localized
+	// Action is the name of the action that the shortcut activates, such as
+	// "win.zoom-in". It is empty if the shortcut isn't bound to an action.
+	Action string
+	// Accels are all the accelerators that do the same thing, such as
+	// "<Control>plus" and "plus" for zooming in.
+	Accels []string
+}
+
+// Shortcut is a keyboard shortcut that activates an action, along with a
+// description of what it does.
+type Shortcut struct {
+	// Accel is the keybinding, written the same way as for
+	// AddActionShortcuts.
+	Accel string
+	// Description describes what the shortcut does, such as "Zoom In".
+	Description locale.Localized
+	// Action is the name of the action to activate, such as "win.zoom-in".
+	Action string
+}
+
+// CallbackShortcut is like Shortcut, except it calls a function instead of
+// activating an action.
+type CallbackShortcut struct {
+	// Accel is the keybinding, written the same way as for
+	// AddCallbackShortcuts.
+	Accel string
+	// Description describes what the shortcut does.
+	Description locale.Localized
+	// Callback is called when the shortcut is triggered.
+	Callback func()
+}
+
+type shortcutDesc struct {
+	section locale.Localized
+	title   locale.Localized
+	action  string
+}
+
+var shortcutRegistry = struct {
+	sync.Mutex
+	// bound holds the accelerators that are bound using any of the helpers.
+	bound map[string]struct{}
+	// descs maps accelerators to their descriptions.
+	descs map[string][]shortcutDesc
+}{
+	bound: make(map[string]struct{}),
+	descs: make(map[string][]shortcutDesc),
+}
+
+// normalizeAccel returns the canonical name of the accelerator, so "<Ctrl>c"
+// and "<Control>c" are the same. Accelerators that GTK cannot parse, such as
+// shortcut trigger strings with alternatives, are returned as-is.
+func normalizeAccel(accel string) string {
+	key, mods, ok := gtk.AcceleratorParse(accel)
+	if !ok {
+		return accel
+	}
+	return gtk.AcceleratorName(key, mods)
+}
+
+// recordShortcuts records that the given accelerators are bound, so they can be
+// shown in the shortcuts window if they're described.
+func recordShortcuts[T any](shortcuts map[string]T) {
+	shortcutRegistry.Lock()
+	defer shortcutRegistry.Unlock()
+
+	for trigger := range shortcuts {
+		// Shortcut triggers may have alternatives separated by "|".
+		for _, accel := range strings.Split(trigger, "|") {
+			shortcutRegistry.bound[normalizeAccel(accel)] = struct{}{}
+		}
+	}
+}
+
+// RecordShortcut records that the given accelerator is bound elsewhere, such as
+// with gtk.Application.SetAccelsForAction. Shortcuts bound using BindKeys,
+// NewKeybinds, AddActionShortcuts or AddCallbackShortcuts are recorded
+// automatically.
+func RecordShortcut(accel string) {
+	recordShortcuts(map[string]struct{}{accel: {}})
+}
+
+// DescribeShortcuts describes the given accelerators under the given section
+// for the shortcuts window. The map maps accelerators, written the same way as
+// they're bound, to what they do. Accelerators that do the same thing should
+// be given the same title, so they're shown together. Only shortcuts that are
+// actually bound are listed, so describing a shortcut that's not always bound
+// is fine. This function should ideally be called only during init.
+func DescribeShortcuts(section locale.Localized, titles map[string]locale.Localized) {
+	shortcutRegistry.Lock()
+	defer shortcutRegistry.Unlock()
+
+	for accel, title := range titles {
+		describeShortcut(accel, shortcutDesc{section: section, title: title})
+	}
+}
+
+func describeShortcut(trigger string, desc shortcutDesc) {
+	for _, accel := range strings.Split(trigger, "|") {
+		accel = normalizeAccel(accel)
+		if !slices.Contains(shortcutRegistry.descs[accel], desc) {
+			shortcutRegistry.descs[accel] = append(shortcutRegistry.descs[accel], desc)
+		}
+	}
+}
+
+// AddDescribedActionShortcuts is like AddActionShortcuts, except each shortcut
+// is also described under the given section, so it's listed in the shortcuts
+// window.
+func AddDescribedActionShortcuts(w gtk.Widgetter, section locale.Localized, shortcuts []Shortcut) {
+	actions := make(map[string]string, len(shortcuts))
+	for _, shortcut := range shortcuts {
+		actions[shortcut.Accel] = shortcut.Action
+	}
+
+	AddActionShortcuts(w, actions)
+	DescribeActionShortcuts(section, shortcuts)
+}
+
+// DescribeActionShortcuts is like DescribeShortcuts, except the shortcuts also
+// name the actions that they activate, so they can be listed in the command
+// palette. The shortcuts must be bound elsewhere, such as using
+// app.Application.AddActionShortcuts.
+func DescribeActionShortcuts(section locale.Localized, shortcuts []Shortcut) {
+	shortcutRegistry.Lock()
+	defer shortcutRegistry.Unlock()
+
+	for _, shortcut := range shortcuts {
+		describeShortcut(shortcut.Accel, shortcutDesc{
+			section: section,
+			title:   shortcut.Description,
+			action:  shortcut.Action,
+		})
+	}
+}
+
+// AddDescribedCallbackShortcuts is like AddCallbackShortcuts, except each
+// shortcut is also described under the given section, so it's listed in the
+// shortcuts window.
+func AddDescribedCallbackShortcuts(w gtk.Widgetter, section locale.Localized, shortcuts []CallbackShortcut) {
+	callbacks := make(map[string]func(), len(shortcuts))
+	for _, shortcut := range shortcuts {
+		callbacks[shortcut.Accel] = shortcut.Callback
+	}
+
+	AddCallbackShortcuts(w, callbacks)
+
+	shortcutRegistry.Lock()
+	defer shortcutRegistry.Unlock()
+
+	for _, shortcut := range shortcuts {
+		describeShortcut(shortcut.Accel, shortcutDesc{
+			section: section,
+			title:   shortcut.Description,
+		})
+	}
+}
+
+// ListShortcuts lists all shortcuts that are bound and described. Shortcuts are
+// sorted by their localized section, then by their localized title.
+func ListShortcuts() []ShortcutInfo {
+	shortcutRegistry.Lock()
+	defer shortcutRegistry.Unlock()
+
+	byDesc := make(map[shortcutDesc]*ShortcutInfo)
+	var infos []*ShortcutInfo
+
+	for accel, descs := range shortcutRegistry.descs {
+		if _, ok := shortcutRegistry.bound[accel]; !ok {
+			continue
+		}
+
+		for _, desc := range descs {
+			info, ok := byDesc[desc]
+			if !ok {
+				info = &ShortcutInfo{
+					Section: desc.section,
+					Title:   desc.title,
+					Action:  desc.action,
+				}
+				byDesc[desc] = info
+				infos = append(infos, info)
+			}
+			info.Accels = append(info.Accels, accel)
+		}
+	}
+
+	list := make([]ShortcutInfo, len(infos))
+	for i, info := range infos {
+		// Shorter accelerators are usually the main ones.
+		slices.SortFunc(info.Accels, func(a, b string) int {
+			if len(a) != len(b) {
+				return len(a) - len(b)
+			}
+			return locale.CompareStrings(a, b)
+		})
+		list[i] = *info
+	}
+
+	slices.SortFunc(list, func(a, b ShortcutInfo) int {
+		if c := locale.CompareStrings(a.Section.String(), b.Section.String()); c != 0 {
+			return c
+		}
+		return locale.CompareStrings(a.Title.String(), b.Title.String())
+	})
+
+	return list
+}
+
+// NewShortcutsWindow creates a new gtk.ShortcutsWindow listing all shortcuts
+// returned by ListShortcuts. Each section is shown as its own group.
+func NewShortcutsWindow() *gtk.ShortcutsWindow {
+	var ui strings.Builder
+	ui.WriteString(`<interface>`)
+	ui.WriteString(`<object class="GtkShortcutsWindow" id="window">`)
+	ui.WriteString(`<property name="modal">1</property>`)
+	ui.WriteString(`<child><object class="GtkShortcutsSection">`)
+	ui.WriteString(`<property name="section-name">shortcuts</property>`)
+
+	shortcuts := ListShortcuts()
+
+	var section string
+	for i, info := range shortcuts {
+		if i == 0 || info.Section.String() != section {
+			if i > 0 {
+				ui.WriteString(`</object></child>`)
+			}
+			section = info.Section.String()
+			ui.WriteString(`<child><object class="GtkShortcutsGroup">`)
+			writeUIProperty(&ui, "title", section)
+		}
+
+		ui.WriteString(`<child><object class="GtkShortcutsShortcut">`)
+		writeUIProperty(&ui, "title", info.Title.String())
+		writeUIProperty(&ui, "accelerator", strings.Join(info.Accels, " "))
+		ui.WriteString(`</object></child>`)
+	}
+	if len(shortcuts) > 0 {
+		ui.WriteString(`</object></child>`)
+	}
+
+	ui.WriteString(`</object></child>`)
+	ui.WriteString(`</object>`)
+	ui.WriteString(`</interface>`)
+
+	builder := gtk.NewBuilderFromString(ui.String())
+	return builder.GetObject("window").Cast().(*gtk.ShortcutsWindow)
+}
+
+func writeUIProperty(ui *strings.Builder, name, value string) {
+	fmt.Fprintf(ui, `<property name="%s">`, name)
+	xml.EscapeText(ui, []byte(value))
+	ui.WriteString(`</property>`)
+}
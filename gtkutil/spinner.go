@@ -0,0 +1,44 @@
+package gtkutil
+
+import (
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+)
+
+var _ = cssutil.WriteCSS(`
+	.spinner-overlay-loading > :not(.spinner-overlay-spinner) {
+		opacity: 0.5;
+	}
+`)
+
+// NewSpinnerOverlay wraps child in an overlay that can show a centered spinner
+// on top of it. Calling the returned function with true dims and disables the
+// child and shows the spinner; calling it with false restores the child. Unlike
+// app.Window.SetLoading, the child stays in place while loading.
+func NewSpinnerOverlay(child gtk.Widgetter) (*gtk.Overlay, func(loading bool)) {
+	spinner := gtk.NewSpinner()
+	spinner.AddCSSClass("spinner-overlay-spinner")
+	spinner.SetSizeRequest(24, 24)
+	spinner.SetHAlign(gtk.AlignCenter)
+	spinner.SetVAlign(gtk.AlignCenter)
+	spinner.SetVisible(false)
+
+	overlay := gtk.NewOverlay()
+	overlay.AddCSSClass("spinner-overlay")
+	overlay.SetChild(child)
+	overlay.AddOverlay(spinner)
+
+	base := gtk.BaseWidget(child)
+
+	return overlay, func(loading bool) {
+		spinner.SetVisible(loading)
+		spinner.SetSpinning(loading)
+		base.SetSensitive(!loading)
+
+		if loading {
+			overlay.AddCSSClass("spinner-overlay-loading")
+		} else {
+			overlay.RemoveCSSClass("spinner-overlay-loading")
+		}
+	}
+}
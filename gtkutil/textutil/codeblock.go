@@ -0,0 +1,68 @@
+package textutil
+
+import (
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+)
+
+var _ = cssutil.WriteCSS(`
+	.textutil-codeblock {
+		border-radius: 6px;
+		background-color: alpha(currentColor, 0.05);
+	}
+	.textutil-codeblock textview,
+	.textutil-codeblock textview text {
+		background: none;
+	}
+	.textutil-codeblock textview {
+		padding: 6px 8px;
+	}
+	.textutil-codeblock-copy {
+		margin: 4px;
+		opacity: 0.75;
+	}
+	.textutil-codeblock-copy:hover {
+		opacity: 1;
+	}
+`)
+
+// NewCodeBlock creates a new widget that shows the given text as a monospace
+// block of code, such as a stack trace or JSON. The text is selectable and
+// scrolls horizontally instead of wrapping. Tabs follow TabWidth, and a button
+// to copy the whole text is shown in the corner.
+func NewCodeBlock(text string) gtk.Widgetter {
+	view := gtk.NewTextView()
+	view.SetEditable(false)
+	view.SetCursorVisible(false)
+	view.SetMonospace(true)
+	view.SetWrapMode(gtk.WrapNone)
+	// Code is written left-to-right even in RTL locales, so don't mirror it.
+	view.SetDirection(gtk.TextDirLTR)
+	view.Buffer().SetText(text)
+
+	TabWidth.SubscribeWidget(view, func() { SetTabSize(view) })
+
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyAutomatic, gtk.PolicyNever)
+	scroll.SetPropagateNaturalHeight(true)
+	scroll.SetChild(view)
+
+	copyButton := gtk.NewButtonFromIconName("edit-copy-symbolic")
+	copyButton.AddCSSClass("flat")
+	copyButton.AddCSSClass("textutil-codeblock-copy")
+	copyButton.SetTooltipText(locale.Get("Copy"))
+	copyButton.SetHAlign(gtk.AlignEnd)
+	copyButton.SetVAlign(gtk.AlignStart)
+	copyButton.ConnectClicked(func() {
+		clipboard := copyButton.Clipboard()
+		clipboard.SetText(text)
+	})
+
+	overlay := gtk.NewOverlay()
+	overlay.AddCSSClass("textutil-codeblock")
+	overlay.SetChild(scroll)
+	overlay.AddOverlay(copyButton)
+
+	return overlay
+}
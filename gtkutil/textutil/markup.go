@@ -0,0 +1,50 @@
+package textutil
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// EscapeMarkup escapes the given string so that it can be safely put into
+// Pango markup. It is glib.MarkupEscapeText: unlike html.EscapeString, control
+// characters are escaped as well, since Pango refuses to parse markup with
+// them.
+func EscapeMarkup(s string) string {
+	return glib.MarkupEscapeText(s)
+}
+
+// Markupf formats the given Pango markup format string like fmt.Sprintf, except
+// all arguments are escaped using EscapeMarkup. The format string itself is not
+// escaped, so it can contain markup, but it must never come from user content.
+//
+// Arguments of type int are given as-is, so that they can be used as widths and
+// precisions, such as with %*d. They must not be formatted using %c.
+func Markupf(format string, args ...any) string {
+	escaped := make([]any, len(args))
+	for i, arg := range args {
+		if _, ok := arg.(int); ok {
+			escaped[i] = arg
+			continue
+		}
+		escaped[i] = escapedArg{arg}
+	}
+	return fmt.Sprintf(format, escaped...)
+}
+
+// escapedArg escapes the formatted value of v.
+type escapedArg struct{ v any }
+
+func (a escapedArg) Format(f fmt.State, verb rune) {
+	s := fmt.Sprintf(fmt.FormatString(f, verb), a.v)
+	io.WriteString(f, EscapeMarkup(s))
+}
+
+// NewMarkupLabel creates a new label with the markup formatted using Markupf.
+func NewMarkupLabel(format string, args ...any) *gtk.Label {
+	label := gtk.NewLabel("")
+	label.SetMarkup(Markupf(format, args...))
+	return label
+}
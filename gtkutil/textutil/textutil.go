@@ -6,7 +6,6 @@ import (
 	"encoding/base64"
 	"fmt"
 	"hash/fnv"
-	"html"
 	"image/color"
 	"log"
 	"math"
@@ -41,10 +40,7 @@ func NewAttrOpacity(alpha float64) *pango.Attribute {
 // ErrorMarkup formats the given message red using Pango markup.
 func ErrorMarkup(msg string) string {
 	msg = strings.TrimPrefix(msg, "error ")
-	return fmt.Sprintf(
-		`<span color="#FF0033"><b>Error!</b></span> %s`,
-		html.EscapeString(msg),
-	)
+	return Markupf(`<span color="#FF0033"><b>Error!</b></span> %s`, msg)
 }
 
 var errorAttrs = Attrs(
@@ -89,6 +85,28 @@ func SetTabSize(text *gtk.TextView) {
 	text.SetTabs(stops)
 }
 
+// measureContext is the Pango context used by MeasureText. It is taken from a
+// label, so it follows the default font settings.
+var measureContext *pango.Context
+
+// MeasureText returns the size in pixels that the given text takes when
+// rendered in the default font with the given attributes, which may be nil.
+// It's useful for sizing columns to their content or deciding when to
+// truncate. It must be called on the main thread.
+func MeasureText(text string, attrs *pango.AttrList) (width, height int) {
+	if measureContext == nil {
+		measureContext = gtk.NewLabel("").PangoContext()
+	}
+
+	layout := pango.NewLayout(measureContext)
+	layout.SetText(text)
+	if attrs != nil {
+		layout.SetAttributes(attrs)
+	}
+
+	return layout.PixelSize()
+}
+
 // RGBHex converts the given color to a HTML hex color string. The alpha value
 // is ignored.
 func RGBHex(c color.RGBA) string {
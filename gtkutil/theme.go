@@ -0,0 +1,32 @@
+package gtkutil
+
+import (
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// OnThemeChange calls f with whether the application is currently dark while w
+// is mapped, and again whenever the style changes between light and dark or
+// the desktop toggles high contrast. f is called immediately once w is mapped
+// so it can apply the current state. Use cssutil.IsHighContrast within f to
+// check for high contrast.
+func OnThemeChange(w gtk.Widgetter, f func(dark bool)) {
+	styles := adw.StyleManagerGetDefault()
+	update := func() { f(styles.Dark()) }
+
+	BindSubscribe(w, func() func() {
+		update()
+
+		handles := []glib.SignalHandle{
+			styles.NotifyProperty("dark", update),
+			styles.NotifyProperty("high-contrast", update),
+		}
+
+		return func() {
+			for _, handle := range handles {
+				styles.HandlerDisconnect(handle)
+			}
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package gtkutil
+
+import (
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app/locale"
+)
+
+// NewLiveTimeAgoLabel creates a new label that shows locale.TimeAgo(t) and
+// keeps it up to date while the label is mapped. The label is re-rendered every
+// minute for recent times and less often for older times, as well as at every
+// midnight. The tooltip shows the full timestamp.
+func NewLiveTimeAgoLabel(t time.Time) *gtk.Label {
+	label := gtk.NewLabel(locale.TimeAgo(t))
+	label.SetTooltipText(locale.Time(t, true))
+
+	BindSubscribe(label, func() func() {
+		var handle glib.SourceHandle
+
+		var update func()
+		update = func() {
+			label.SetText(locale.TimeAgo(t))
+
+			next := timeAgoRefresh(t, time.Now())
+			handle = glib.TimeoutAdd(uint(next.Milliseconds()), update)
+		}
+		update()
+
+		return func() { glib.SourceRemove(handle) }
+	})
+
+	return label
+}
+
+// timeAgoRefresh returns the duration after now that the TimeAgo of t should
+// be rendered again.
+func timeAgoRefresh(t, now time.Time) time.Duration {
+	var refresh time.Duration
+	switch age := now.Sub(t); {
+	case age < time.Hour:
+		refresh = time.Minute
+	case age < locale.Day:
+		refresh = 10 * time.Minute
+	default:
+		refresh = time.Hour
+	}
+
+	// The day always changes at midnight, so render again right after it.
+	now = now.Local()
+	y, m, d := now.Date()
+	midnight := time.Date(y, m, d+1, 0, 0, 0, 0, now.Location()).Sub(now)
+
+	// Add a bit of leeway so that the timer doesn't fire right before the
+	// boundary.
+	return min(refresh, midnight+time.Second)
+}
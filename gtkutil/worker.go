@@ -0,0 +1,110 @@
+package gtkutil
+
+import (
+	"context"
+	"sync"
+)
+
+// Worker runs queued jobs one at a time on a dedicated goroutine. Unlike
+// Async, jobs are never run concurrently, and both the jobs and their returned
+// callbacks run in the order that they were queued. This is useful for
+// background work that must not be reordered, such as sequential disk writes.
+type Worker struct {
+	mu      sync.Mutex
+	jobs    []workerJob
+	closed  bool
+	wake    chan struct{}
+	stopped chan struct{}
+}
+
+type workerJob struct {
+	ctx  context.Context
+	work func() func()
+}
+
+// NewWorker creates a new Worker and starts its goroutine. Call Close to stop
+// it.
+func NewWorker() *Worker {
+	w := &Worker{
+		wake:    make(chan struct{}, 1),
+		stopped: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Queue queues work to be run on the worker's goroutine after all previously
+// queued jobs. The callback returned by work is run in the main thread. If ctx
+// is cancelled before work is run or before the callback is run, then it is
+// skipped. Queue never blocks, and it does nothing once the worker is closed.
+func (w *Worker) Queue(ctx context.Context, work func() func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return
+	}
+
+	w.jobs = append(w.jobs, workerJob{ctx, work})
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops accepting new jobs and blocks until all queued jobs are done.
+// Their callbacks may still be run in the main thread after Close returns.
+func (w *Worker) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.wake)
+	}
+	w.mu.Unlock()
+
+	<-w.stopped
+}
+
+func (w *Worker) run() {
+	defer close(w.stopped)
+
+	for {
+		w.mu.Lock()
+		jobs := w.jobs
+		w.jobs = nil
+		closed := w.closed
+		w.mu.Unlock()
+
+		for _, job := range jobs {
+			job.do()
+		}
+
+		if len(jobs) > 0 {
+			continue
+		}
+
+		if closed {
+			return
+		}
+
+		<-w.wake
+	}
+}
+
+func (j workerJob) do() {
+	select {
+	case <-j.ctx.Done():
+		return
+	default:
+	}
+
+	fn := j.work()
+	if fn == nil {
+		return
+	}
+
+	// IdleAdd callbacks are run in the order that they're added, so the
+	// callbacks are kept in the same order as the jobs.
+	IdleAddContext(j.ctx, fn)
+}
@@ -10,3 +10,9 @@ import (
 func WriteFile(path string, b []byte) error {
 	return osutil.WriteFile(path, b)
 }
+
+// ReadFile reads the file in path. It should be used to read files written by
+// WriteFile.
+func ReadFile(path string) ([]byte, error) {
+	return osutil.ReadFile(path)
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"log"
 	"time"
 
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
@@ -15,32 +16,97 @@ type SaverWidget interface {
 	SaveEnd()
 }
 
-// ConfigStore implements store debouncing for a widget.
+// ConfigStore implements store debouncing for a widget. Its methods must only
+// be called on the main thread.
 type ConfigStore struct {
 	Widget  SaverWidget
 	Minimum time.Duration
+	// OnError is called on the main loop when a save fails. If nil, then the
+	// error is logged. Only saves created using NewConfigStoreWithError can
+	// fail.
+	OnError func(error)
 
-	snapshot    func() (save func())
+	snapshot    func() (save func() error)
+	saving      chan struct{} // non-nil while saving, closed when done
 	isSaving    bool
 	needsSaving bool
+	closed      bool
 }
 
 // NewConfigStore creates a new ConfigStore instance. save is called in a
 // goroutine.
 func NewConfigStore(snapshot func() (save func())) ConfigStore {
+	return NewConfigStoreWithError(func() func() error {
+		save := snapshot()
+		return func() error {
+			save()
+			return nil
+		}
+	})
+}
+
+// NewConfigStoreWithError is like NewConfigStore, except save may return an
+// error, which is given to OnError.
+func NewConfigStoreWithError(snapshot func() (save func() error)) ConfigStore {
 	return ConfigStore{snapshot: snapshot}
 }
 
+func (s *ConfigStore) onError(err error) {
+	if s.OnError != nil {
+		s.OnError(err)
+	} else {
+		log.Println("config: cannot save:", err)
+	}
+}
+
+// Save schedules a save. If a save is already in progress, then another one
+// is done once it's finished. Save does nothing once the store is closed.
 func (s *ConfigStore) Save() {
+	if s.closed {
+		return
+	}
+
 	s.needsSaving = true
 	s.save()
 }
 
+// Flush synchronously waits for any ongoing save to finish, and then runs any
+// pending save immediately, bypassing Minimum. It is meant to be called on
+// shutdown so that the last change isn't lost. Flush blocks the main loop
+// while saving.
+func (s *ConfigStore) Flush() {
+	if s.saving != nil {
+		<-s.saving
+	}
+
+	if !s.needsSaving {
+		return
+	}
+	s.needsSaving = false
+
+	save := s.snapshot()
+	if err := save(); err != nil {
+		s.onError(err)
+	}
+}
+
+// Close flushes any pending save and closes the store. Future calls to Save
+// are ignored.
+func (s *ConfigStore) Close() {
+	if s.closed {
+		return
+	}
+
+	s.Flush()
+	s.closed = true
+}
+
 func (s *ConfigStore) save() {
 	if s.isSaving {
 		return
 	}
 	s.isSaving = true
+	s.needsSaving = false
 
 	if s.Widget != nil {
 		s.Widget.SaveBegin()
@@ -49,13 +115,17 @@ func (s *ConfigStore) save() {
 	min := s.Minimum
 	save := s.snapshot()
 
+	saving := make(chan struct{})
+	s.saving = saving
+
 	go func() {
 		var ch <-chan time.Time
 		if min > 0 {
 			ch = time.After(min)
 		}
 
-		save()
+		err := save()
+		close(saving)
 
 		if ch != nil {
 			<-ch
@@ -67,9 +137,13 @@ func (s *ConfigStore) save() {
 			}
 
 			s.isSaving = false
+			s.saving = nil
+
+			if err != nil {
+				s.onError(err)
+			}
 
-			if s.needsSaving {
-				s.needsSaving = false
+			if s.needsSaving && !s.closed {
 				s.save()
 			}
 		})
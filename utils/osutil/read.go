@@ -0,0 +1,41 @@
+package osutil
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/gofrs/flock"
+	"github.com/pkg/errors"
+)
+
+// ReadFile reads the file in path. It is the counterpart of WriteFile: on
+// platforms where WriteFile locks the file instead of atomically renaming it,
+// ReadFile acquires a shared lock on the file before reading, so it never
+// observes a half-written file. Elsewhere, it is the same as os.ReadFile.
+func ReadFile(path string) ([]byte, error) {
+	if !preferFileLocking {
+		return os.ReadFile(path)
+	}
+
+	// Locking creates the file if it doesn't exist, so make sure that it does
+	// first. This preserves os.ReadFile's error.
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	lock := flock.New(path)
+
+	if err := lock.RLock(); err != nil {
+		return nil, errors.Wrap(err, "cannot lock file before reading")
+	}
+	defer func() {
+		if err := lock.Unlock(); err != nil {
+			slog.Error(
+				"failed to unlock file after reading",
+				"path", path,
+				"err", err)
+		}
+	}()
+
+	return os.ReadFile(path)
+}
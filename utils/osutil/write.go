@@ -25,14 +25,36 @@ const preferFileLocking = runtime.GOOS == "windows"
 
 // UseFile is a lower-level function that opens a file and calls fn with it. The
 // file is closed after fn returns. The file may be a temporary file so that it
-// can be atomically moved.
+// can be atomically moved. The file is synced to disk before UseFile returns.
 func UseFile(path string, fn func(*os.File) error) error {
-	return UseFileWithPattern(path, ".tmp.*", fn)
+	return UseFileWithOpts(path, UseFileOpts{Sync: true}, fn)
 }
 
 // UseFileWithPattern is the same as UseFile, but it also takes a temporary file
-// pattern. The pattern may not be used on all platforms.
+// pattern. The pattern may not be used on all platforms. Unlike UseFile, the
+// file is not synced to disk, so it is more suitable for caches.
 func UseFileWithPattern(path, tmpPattern string, fn func(*os.File) error) error {
+	return UseFileWithOpts(path, UseFileOpts{TmpPattern: tmpPattern}, fn)
+}
+
+// UseFileOpts contains options for UseFileWithOpts.
+type UseFileOpts struct {
+	// TmpPattern is the temporary file pattern. If empty, then ".tmp.*" is
+	// used. The pattern may not be used on all platforms.
+	TmpPattern string
+	// Sync, if true, will fsync the file before it is closed, as well as its
+	// parent directory after it is moved, on a best-effort basis. This makes
+	// sure that the file is not truncated or lost if the system crashes right
+	// after, at the cost of performance.
+	Sync bool
+}
+
+// UseFileWithOpts is the same as UseFile, but it takes in UseFileOpts.
+func UseFileWithOpts(path string, opts UseFileOpts, fn func(*os.File) error) error {
+	if opts.TmpPattern == "" {
+		opts.TmpPattern = ".tmp.*"
+	}
+
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
 		return errors.Wrap(err, "cannot mkdir -p")
@@ -64,8 +86,14 @@ func UseFileWithPattern(path, tmpPattern string, fn func(*os.File) error) error
 		if err := fn(f); err != nil {
 			return err
 		}
+
+		if opts.Sync {
+			if err := f.Sync(); err != nil {
+				return errors.Wrap(err, "cannot sync file")
+			}
+		}
 	} else {
-		f, err := os.CreateTemp(dir, tmpPattern)
+		f, err := os.CreateTemp(dir, opts.TmpPattern)
 		if err != nil {
 			return errors.Wrap(err, "cannot mktemp")
 		}
@@ -76,6 +104,12 @@ func UseFileWithPattern(path, tmpPattern string, fn func(*os.File) error) error
 			return err
 		}
 
+		if opts.Sync {
+			if err := f.Sync(); err != nil {
+				return errors.Wrap(err, "cannot sync temp file")
+			}
+		}
+
 		if err := f.Close(); err != nil {
 			return errors.Wrap(err, "temp file error")
 		}
@@ -83,7 +117,32 @@ func UseFileWithPattern(path, tmpPattern string, fn func(*os.File) error) error
 		if err := os.Rename(f.Name(), path); err != nil {
 			return errors.Wrap(err, "cannot swap new prefs file")
 		}
+
+		if opts.Sync {
+			syncDir(dir)
+		}
 	}
 
 	return nil
 }
+
+// syncDir fsyncs the given directory so that a rename inside it is persisted.
+// Not all platforms and filesystems support this, so errors are only logged.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		slog.Debug(
+			"cannot open directory to sync",
+			"path", dir,
+			"err", err)
+		return
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		slog.Debug(
+			"cannot sync directory",
+			"path", dir,
+			"err", err)
+	}
+}